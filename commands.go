@@ -0,0 +1,88 @@
+package main
+
+import (
+	"api-gateway/internal/config"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// printUsage describes the available subcommands
+func printUsage() {
+	fmt.Fprint(os.Stderr, `Usage: api-gateway [command] [flags]
+
+Commands:
+  serve      run the gateway (default)
+  validate   check a config file and exit non-zero if it is invalid
+  schema     print a JSON Schema for the config file format
+  help       show this message
+
+Run "api-gateway <command> -h" for the flags of a command.
+`)
+}
+
+// validateCommand loads and checks a config file without starting the
+// gateway. It returns the process exit code.
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFlag := flags.String("config", "", "path to the main config file (overrides CONFIG_PATH)")
+	strict := flags.Bool("strict", false, "treat warnings as errors")
+	flags.Parse(args)
+
+	config.LoadEnv()
+	path := config.ResolvePath(*configFlag)
+
+	warnings, err := config.ValidateFile(path)
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			for _, issue := range validationErr.Issues {
+				fmt.Fprintln(os.Stderr, issue)
+			}
+			errorCount := validationErr.Errors()
+			fmt.Fprintf(os.Stderr, "%s: %d error(s), %d warning(s)\n", path, errorCount, len(validationErr.Issues)-errorCount)
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+
+	if *strict && len(warnings) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d warning(s) treated as errors\n", path, len(warnings))
+		return 1
+	}
+
+	fmt.Printf("%s: configuration is valid\n", path)
+	return 0
+}
+
+// schemaCommand writes the config JSON Schema to stdout or a file. It
+// returns the process exit code.
+func schemaCommand(args []string) int {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	output := flags.String("output", "", "write the schema to this file instead of stdout")
+	flags.Parse(args)
+
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode schema: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write schema: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package balancer
+
+import (
+	"api-gateway/internal/utils"
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// virtualNodes is the number of ring points per unit of target weight
+const virtualNodes = 100
+
+// roundRobin cycles through the candidates in order
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (r *roundRobin) pick(_ *fiber.Ctx, candidates []*Target) *Target {
+	return candidates[(r.next.Add(1)-1)%uint64(len(candidates))]
+}
+
+// weightedRoundRobin implements smooth weighted round-robin, spreading picks
+// of heavier targets evenly instead of in bursts
+type weightedRoundRobin struct {
+	mu sync.Mutex
+}
+
+func (w *weightedRoundRobin) pick(_ *fiber.Ctx, candidates []*Target) *Target {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var best *Target
+	total := 0
+	for _, target := range candidates {
+		target.currentWeight += target.Weight
+		total += target.Weight
+		if best == nil || target.currentWeight > best.currentWeight {
+			best = target
+		}
+	}
+	best.currentWeight -= total
+	return best
+}
+
+// leastConnections picks the candidate with the fewest in-flight requests
+// relative to its weight. The scan starts at a rotating offset so ties, such
+// as idle targets, are spread round-robin instead of all going to the first.
+type leastConnections struct {
+	next atomic.Uint64
+}
+
+func (l *leastConnections) pick(_ *fiber.Ctx, candidates []*Target) *Target {
+	start := (l.next.Add(1) - 1) % uint64(len(candidates))
+	best := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		target := candidates[(start+uint64(i))%uint64(len(candidates))]
+		// Compare active/weight without division
+		if target.Active()*int64(best.Weight) < best.Active()*int64(target.Weight) {
+			best = target
+		}
+	}
+	return best
+}
+
+// randomTwoChoices samples two distinct candidates and picks the less loaded one
+type randomTwoChoices struct{}
+
+func (randomTwoChoices) pick(_ *fiber.Ctx, candidates []*Target) *Target {
+	i := rand.IntN(len(candidates))
+	j := rand.IntN(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+
+	first, second := candidates[i], candidates[j]
+	if second.Active()*int64(first.Weight) < first.Active()*int64(second.Weight) {
+		return second
+	}
+	return first
+}
+
+// consistentHash maps a request key onto a hash ring so the same key keeps
+// reaching the same target while the target set is stable
+type consistentHash struct {
+	ring     []ringPoint
+	hashOn   string
+	hashKey  string
+	fallback roundRobin
+}
+
+type ringPoint struct {
+	hash   uint64
+	target *Target
+}
+
+func newConsistentHash(targets []*Target, hashOn, hashKey string) *consistentHash {
+	h := &consistentHash{hashOn: hashOn, hashKey: hashKey}
+	for _, target := range targets {
+		for i := 0; i < virtualNodes*target.Weight; i++ {
+			h.ring = append(h.ring, ringPoint{hash: hashString(target.URL + "#" + strconv.Itoa(i)), target: target})
+		}
+	}
+	sort.Slice(h.ring, func(i, j int) bool { return h.ring[i].hash < h.ring[j].hash })
+	return h
+}
+
+func (h *consistentHash) pick(c *fiber.Ctx, candidates []*Target) *Target {
+	key := h.key(c)
+	if key == "" {
+		// Requests without a key are spread evenly
+		return h.fallback.pick(c, candidates)
+	}
+
+	// Walk clockwise from the key's position to the first candidate
+	hash := hashString(key)
+	start := sort.Search(len(h.ring), func(i int) bool { return h.ring[i].hash >= hash })
+	for i := 0; i < len(h.ring); i++ {
+		point := h.ring[(start+i)%len(h.ring)]
+		if contains(candidates, point.target) {
+			return point.target
+		}
+	}
+	return candidates[0]
+}
+
+// key extracts the value requests are hashed on
+func (h *consistentHash) key(c *fiber.Ctx) string {
+	switch h.hashOn {
+	case "header":
+		return c.Get(h.hashKey)
+	case "cookie":
+		return c.Cookies(h.hashKey)
+	default:
+		return utils.GetClientIP(c)
+	}
+}
+
+// hashString hashes value with FNV-1a followed by a splitmix64 finalizer,
+// which spreads similar inputs such as "url#1" and "url#2" across the ring
+func hashString(value string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(value))
+
+	h := hasher.Sum64()
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,57 @@
+package balancer
+
+import (
+	"api-gateway/internal/config"
+	"testing"
+)
+
+func TestLeastConnectionsSpreadsIdleTargets(t *testing.T) {
+	pool := NewPool("least-connections-idle", []config.TargetConfig{
+		{URL: "http://10.0.0.1"},
+		{URL: "http://10.0.0.2"},
+		{URL: "http://10.0.0.3"},
+	}, &config.LoadBalancingConfig{Algorithm: LeastConnections}, nil)
+
+	picks := make(map[string]int)
+	for range 300 {
+		target := pool.Pick(nil)
+		if target == nil {
+			t.Fatal("no target picked")
+		}
+		picks[target.URL]++
+	}
+
+	for _, target := range pool.Targets() {
+		if picks[target.URL] != 100 {
+			t.Errorf("%s picked %d times, want 100 (picks: %v)", target.URL, picks[target.URL], picks)
+		}
+	}
+}
+
+func TestLeastConnectionsPrefersLeastLoaded(t *testing.T) {
+	pool := NewPool("least-connections-busy", []config.TargetConfig{
+		{URL: "http://10.0.0.1"},
+		{URL: "http://10.0.0.2", Weight: 2},
+		{URL: "http://10.0.0.3"},
+	}, &config.LoadBalancingConfig{Algorithm: LeastConnections}, nil)
+
+	targets := pool.Targets()
+	targets[0].Acquire()
+	targets[1].Acquire()
+	targets[2].Acquire()
+	targets[2].Acquire()
+	defer func() {
+		for _, target := range targets {
+			for target.Active() > 0 {
+				target.Release()
+			}
+		}
+	}()
+
+	// 10.0.0.2 carries one request on twice the weight of the others
+	for range 10 {
+		if target := pool.Pick(nil); target != targets[1] {
+			t.Fatalf("picked %s, want %s", target.URL, targets[1].URL)
+		}
+	}
+}
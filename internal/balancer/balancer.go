@@ -0,0 +1,143 @@
+package balancer
+
+import (
+	"api-gateway/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Algorithm names accepted in load_balancing.algorithm
+const (
+	RoundRobin         = "round_robin"
+	WeightedRoundRobin = "weighted_round_robin"
+	LeastConnections   = "least_connections"
+	RandomTwoChoices   = "random_two_choices"
+	ConsistentHash     = "consistent_hash"
+)
+
+// Target is a single upstream instance of a service
+type Target struct {
+	URL    string
+	Weight int
+
+	// state is shared with other pools containing the same target
+	state *State
+	// currentWeight is the smooth weighted round-robin state, guarded by the
+	// algorithm's mutex
+	currentWeight int
+}
+
+// Acquire marks a request as in flight on the target
+func (t *Target) Acquire() {
+	t.state.active.Add(1)
+}
+
+// Release marks an in-flight request on the target as finished
+func (t *Target) Release() {
+	t.state.active.Add(-1)
+}
+
+// Active returns the number of in-flight requests on the target
+func (t *Target) Active() int64 {
+	return t.state.Active()
+}
+
+// State returns the runtime state shared by every pool containing the target
+func (t *Target) State() *State {
+	return t.state
+}
+
+// algorithm picks one of the candidate targets for a request
+type algorithm interface {
+	pick(c *fiber.Ctx, candidates []*Target) *Target
+}
+
+// Pool balances requests across the targets of one service
+type Pool struct {
+	service   string
+	targets   []*Target
+	algorithm algorithm
+	// outlier is nil when outlier detection is disabled
+	outlier *outlierDetection
+}
+
+// NewPool builds a pool for the targets, load balancing and outlier detection
+// settings of a service
+func NewPool(service string, targets []config.TargetConfig, settings *config.LoadBalancingConfig, outlier *config.OutlierDetectionConfig) *Pool {
+	pool := &Pool{
+		service: service,
+		targets: make([]*Target, 0, len(targets)),
+		outlier: newOutlierDetection(outlier),
+	}
+	for _, target := range targets {
+		weight := target.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		pool.targets = append(pool.targets, &Target{
+			URL:    target.URL,
+			Weight: weight,
+			state:  StateFor(service, target.URL),
+		})
+	}
+
+	if settings == nil {
+		settings = &config.LoadBalancingConfig{}
+	}
+
+	switch settings.Algorithm {
+	case WeightedRoundRobin:
+		pool.algorithm = &weightedRoundRobin{}
+	case LeastConnections:
+		pool.algorithm = &leastConnections{}
+	case RandomTwoChoices:
+		pool.algorithm = randomTwoChoices{}
+	case ConsistentHash:
+		pool.algorithm = newConsistentHash(pool.targets, settings.HashOn, settings.HashKey)
+	default:
+		pool.algorithm = &roundRobin{}
+	}
+
+	return pool
+}
+
+// Targets returns every target in the pool
+func (p *Pool) Targets() []*Target {
+	return p.targets
+}
+
+// Pick selects an available target for the request, never returning one of
+// exclude. It returns nil when no target is left to choose from.
+func (p *Pool) Pick(c *fiber.Ctx, exclude ...*Target) *Target {
+	// Only copy the targets when at least one has to be filtered out
+	candidates := p.targets
+	for i, target := range p.targets {
+		if !target.state.Available() || contains(exclude, target) {
+			candidates = append(make([]*Target, 0, len(p.targets)), p.targets[:i]...)
+			for _, rest := range p.targets[i+1:] {
+				if rest.state.Available() && !contains(exclude, rest) {
+					candidates = append(candidates, rest)
+				}
+			}
+			break
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	default:
+		return p.algorithm.pick(c, candidates)
+	}
+}
+
+func contains(targets []*Target, target *Target) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
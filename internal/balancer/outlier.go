@@ -0,0 +1,124 @@
+package balancer
+
+import (
+	"api-gateway/internal/config"
+	"log"
+	"sync"
+	"time"
+)
+
+// Defaults applied to unset outlier detection settings
+const (
+	defaultConsecutiveErrors = 5
+	defaultBaseEjectionTime  = 30 * time.Second
+	defaultMaxEjectionTime   = 5 * time.Minute
+	defaultMaxEjectedPercent = 50
+)
+
+// outlierDetection ejects targets that keep failing real traffic
+type outlierDetection struct {
+	consecutiveErrors int
+	baseEjectionTime  time.Duration
+	maxEjectionTime   time.Duration
+	maxEjectedPercent int
+
+	// mu serializes ejections so the ejected percentage is never exceeded
+	mu sync.Mutex
+}
+
+// newOutlierDetection fills unset settings, returning nil when outlier
+// detection is disabled
+func newOutlierDetection(settings *config.OutlierDetectionConfig) *outlierDetection {
+	if settings == nil || settings.Enabled == nil || !*settings.Enabled {
+		return nil
+	}
+
+	outlier := &outlierDetection{
+		consecutiveErrors: settings.ConsecutiveErrors,
+		baseEjectionTime:  settings.BaseEjectionTime,
+		maxEjectionTime:   settings.MaxEjectionTime,
+		maxEjectedPercent: settings.MaxEjectedPercent,
+	}
+	if outlier.consecutiveErrors == 0 {
+		outlier.consecutiveErrors = defaultConsecutiveErrors
+	}
+	if outlier.baseEjectionTime == 0 {
+		outlier.baseEjectionTime = defaultBaseEjectionTime
+	}
+	if outlier.maxEjectionTime == 0 {
+		outlier.maxEjectionTime = max(defaultMaxEjectionTime, outlier.baseEjectionTime)
+	}
+	if outlier.maxEjectedPercent == 0 {
+		outlier.maxEjectedPercent = defaultMaxEjectedPercent
+	}
+	return outlier
+}
+
+// ejectionTime doubles the base ejection time for every previous ejection,
+// capped at the max ejection time
+func (o *outlierDetection) ejectionTime(ejections int) time.Duration {
+	duration := o.baseEjectionTime
+	for i := 1; i < ejections && duration < o.maxEjectionTime; i++ {
+		duration *= 2
+	}
+	return min(duration, o.maxEjectionTime)
+}
+
+// Report records the outcome of a request forwarded to target. failed is
+// true for connection errors, timeouts and 5xx responses. A target is
+// ejected once it reaches the consecutive error threshold, unless that would
+// exceed the max ejected percentage of the pool.
+func (p *Pool) Report(target *Target, failed bool) {
+	if p.outlier == nil {
+		return
+	}
+
+	state := target.state
+	state.outlierMu.Lock()
+	defer state.outlierMu.Unlock()
+
+	if !failed {
+		state.consecutiveErrors = 0
+		return
+	}
+
+	state.consecutiveErrors++
+	if state.consecutiveErrors < p.outlier.consecutiveErrors || state.Ejected() {
+		return
+	}
+
+	p.outlier.mu.Lock()
+	defer p.outlier.mu.Unlock()
+
+	if !p.canEject() {
+		return
+	}
+
+	// Forget earlier ejections once the target has behaved for a full max
+	// ejection time since it was last returned to rotation
+	now := time.Now()
+	if until := state.ejectedUntil.Load(); until != 0 && now.Sub(time.Unix(0, until)) > p.outlier.maxEjectionTime {
+		state.ejections = 0
+	}
+
+	state.ejections++
+	duration := p.outlier.ejectionTime(state.ejections)
+	state.ejectedUntil.Store(now.Add(duration).UnixNano())
+	state.consecutiveErrors = 0
+
+	log.Printf("Upstream %s target %s ejected for %s after %d consecutive errors", p.service, target.URL, duration, p.outlier.consecutiveErrors)
+}
+
+// canEject reports whether one more target may be ejected without exceeding
+// the max ejected percentage. At least one target always stays in rotation.
+func (p *Pool) canEject() bool {
+	ejected := 0
+	for _, target := range p.targets {
+		if target.state.Ejected() {
+			ejected++
+		}
+	}
+
+	allowed := min(len(p.targets)*p.outlier.maxEjectedPercent/100, len(p.targets)-1)
+	return ejected < allowed
+}
@@ -0,0 +1,80 @@
+package balancer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is the runtime state of one upstream target. It is shared by every
+// pool that contains the target so it survives config reloads.
+type State struct {
+	// active counts in-flight requests for connection-aware algorithms
+	active atomic.Int64
+	// unhealthy is set by active health checks
+	unhealthy atomic.Bool
+	// ejectedUntil is the UnixNano time until which outlier detection keeps
+	// the target out of rotation
+	ejectedUntil atomic.Int64
+
+	// outlierMu guards the outlier detection counters
+	outlierMu         sync.Mutex
+	consecutiveErrors int
+	ejections         int
+}
+
+var (
+	// states holds the State of every target keyed by service and URL
+	states   = make(map[string]*State)
+	statesMu sync.Mutex
+)
+
+// StateFor returns the shared state of a service target, creating it on first use
+func StateFor(service, url string) *State {
+	key := service + "|" + url
+
+	statesMu.Lock()
+	defer statesMu.Unlock()
+
+	state, exists := states[key]
+	if !exists {
+		state = &State{}
+		states[key] = state
+	}
+	return state
+}
+
+// Active returns the number of in-flight requests on the target
+func (s *State) Active() int64 {
+	return s.active.Load()
+}
+
+// Healthy reports whether active health checks consider the target healthy
+func (s *State) Healthy() bool {
+	return !s.unhealthy.Load()
+}
+
+// SetHealthy records the result of active health checks
+func (s *State) SetHealthy(healthy bool) {
+	s.unhealthy.Store(!healthy)
+}
+
+// Ejected reports whether outlier detection currently keeps the target out
+// of rotation
+func (s *State) Ejected() bool {
+	return time.Now().UnixNano() < s.ejectedUntil.Load()
+}
+
+// EjectedUntil returns when the current ejection ends, or the zero time when
+// the target is not ejected
+func (s *State) EjectedUntil() time.Time {
+	if !s.Ejected() {
+		return time.Time{}
+	}
+	return time.Unix(0, s.ejectedUntil.Load())
+}
+
+// Available reports whether the target may receive traffic
+func (s *State) Available() bool {
+	return s.Healthy() && !s.Ejected()
+}
@@ -0,0 +1,265 @@
+package breaker
+
+import (
+	"api-gateway/internal/config"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults applied to unset circuit breaker settings
+const (
+	defaultFailureRatio     = 0.5
+	defaultMinimumRequests  = 20
+	defaultWindow           = 10 * time.Second
+	defaultOpenDuration     = 30 * time.Second
+	defaultHalfOpenRequests = 5
+)
+
+// State is the position of a circuit breaker
+type State string
+
+const (
+	// Closed lets all requests through while counting failures
+	Closed State = "closed"
+	// Open rejects all requests until the open duration has passed
+	Open State = "open"
+	// HalfOpen lets a limited number of probe requests through
+	HalfOpen State = "half_open"
+)
+
+// Event describes a circuit breaker state change
+type Event struct {
+	Service string    `json:"service"`
+	From    State     `json:"from"`
+	To      State     `json:"to"`
+	Time    time.Time `json:"time"`
+}
+
+// Status reports the current state of a service's circuit breaker
+type Status struct {
+	Service  string    `json:"service"`
+	State    State     `json:"state"`
+	Since    time.Time `json:"since"`
+	Requests int       `json:"requests"`
+	Failures int       `json:"failures"`
+}
+
+// counts is the shared runtime state of one service's breaker. It is kept
+// across config reloads so a reload does not close an open circuit.
+type counts struct {
+	mu sync.Mutex
+
+	state State
+	since time.Time
+
+	// Closed: outcomes within the current window
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	// HalfOpen: admitted probes and their successes
+	probes    int
+	successes int
+}
+
+// Breaker is a service's circuit breaker built for one configuration snapshot
+type Breaker struct {
+	service          string
+	failureRatio     float64
+	minimumRequests  int
+	window           time.Duration
+	openDuration     time.Duration
+	halfOpenRequests int
+
+	counts *counts
+}
+
+var (
+	// registry holds the counts of every service keyed by name
+	registry   = make(map[string]*counts)
+	registryMu sync.Mutex
+
+	listeners   []func(Event)
+	listenersMu sync.RWMutex
+)
+
+// New builds the circuit breaker of a service, returning nil when it is
+// disabled. A nil *Breaker lets every request through.
+func New(service string, settings *config.CircuitBreakerConfig) *Breaker {
+	if settings == nil || settings.Enabled == nil || !*settings.Enabled {
+		return nil
+	}
+
+	b := &Breaker{
+		service:          service,
+		failureRatio:     settings.FailureRatio,
+		minimumRequests:  settings.MinimumRequests,
+		window:           settings.Window,
+		openDuration:     settings.OpenDuration,
+		halfOpenRequests: settings.HalfOpenRequests,
+		counts:           countsFor(service),
+	}
+	if b.failureRatio == 0 {
+		b.failureRatio = defaultFailureRatio
+	}
+	if b.minimumRequests == 0 {
+		b.minimumRequests = defaultMinimumRequests
+	}
+	if b.window == 0 {
+		b.window = defaultWindow
+	}
+	if b.openDuration == 0 {
+		b.openDuration = defaultOpenDuration
+	}
+	if b.halfOpenRequests == 0 {
+		b.halfOpenRequests = defaultHalfOpenRequests
+	}
+	return b
+}
+
+// countsFor returns the shared counts of a service, creating them on first use
+func countsFor(service string) *counts {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	c, exists := registry[service]
+	if !exists {
+		now := time.Now()
+		c = &counts{state: Closed, since: now, windowStart: now}
+		registry[service] = c
+	}
+	return c
+}
+
+// OnStateChange registers fn to be called after every state change
+func OnStateChange(fn func(Event)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Allow reports whether a request may be sent to the service. When it may,
+// done must be called with the outcome of the request; otherwise retryAfter
+// tells the client when to try again.
+func (b *Breaker) Allow() (done func(success bool), retryAfter time.Duration, ok bool) {
+	if b == nil {
+		return func(bool) {}, 0, true
+	}
+
+	var event *Event
+	defer func() { emit(event) }()
+
+	c := b.counts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	switch c.state {
+	case Open:
+		reopen := c.since.Add(b.openDuration)
+		if now.Before(reopen) {
+			return nil, reopen.Sub(now), false
+		}
+		event = b.transition(HalfOpen, now)
+		fallthrough
+	case HalfOpen:
+		if c.probes >= b.halfOpenRequests {
+			return nil, time.Second, false
+		}
+		c.probes++
+	}
+
+	state := c.state
+	since := c.since
+	return func(success bool) { b.record(state, since, success) }, 0, true
+}
+
+// record applies the outcome of a request admitted while the breaker was in
+// state since the given time. Outcomes from an earlier state are ignored.
+func (b *Breaker) record(state State, since time.Time, success bool) {
+	var event *Event
+	defer func() { emit(event) }()
+
+	c := b.counts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != state || !c.since.Equal(since) {
+		return
+	}
+
+	now := time.Now()
+	switch c.state {
+	case Closed:
+		if now.Sub(c.windowStart) >= b.window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+		c.requests++
+		if !success {
+			c.failures++
+		}
+		if c.requests >= b.minimumRequests && float64(c.failures)/float64(c.requests) >= b.failureRatio {
+			event = b.transition(Open, now)
+		}
+	case HalfOpen:
+		if !success {
+			event = b.transition(Open, now)
+			return
+		}
+		c.successes++
+		if c.successes >= b.halfOpenRequests {
+			event = b.transition(Closed, now)
+		}
+	}
+}
+
+// transition moves the breaker to state and resets its counters. The caller
+// must hold the counts lock and emit the returned event after releasing it.
+func (b *Breaker) transition(state State, now time.Time) *Event {
+	c := b.counts
+	event := &Event{Service: b.service, From: c.state, To: state, Time: now}
+
+	c.state, c.since = state, now
+	c.windowStart, c.requests, c.failures = now, 0, 0
+	c.probes, c.successes = 0, 0
+	return event
+}
+
+// emit logs event and passes it to the registered listeners
+func emit(event *Event) {
+	if event == nil {
+		return
+	}
+	log.Printf("Circuit breaker for %s changed from %s to %s", event.Service, event.From, event.To)
+
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(*event)
+	}
+}
+
+// Statuses returns the state of every circuit breaker that has been used
+func Statuses() []Status {
+	registryMu.Lock()
+	statuses := make([]Status, 0, len(registry))
+	for service, c := range registry {
+		c.mu.Lock()
+		statuses = append(statuses, Status{
+			Service:  service,
+			State:    c.state,
+			Since:    c.since,
+			Requests: c.requests,
+			Failures: c.failures,
+		})
+		c.mu.Unlock()
+	}
+	registryMu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Service < statuses[j].Service
+	})
+	return statuses
+}
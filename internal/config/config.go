@@ -1,114 +1,319 @@
 package config
 
 import (
-	"fmt"
 	"log"
-	"os"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 	pkgConfig "github.com/kerimovok/go-pkg-utils/config"
-	"gopkg.in/yaml.v3"
 )
 
 // Struct for rate limit settings
 type RateLimitConfig struct {
-	Enabled     *bool         `yaml:"enabled"`
-	MaxRequests int           `yaml:"max_requests" validate:"required_if=Enabled true,gt=0"`
-	Duration    time.Duration `yaml:"duration" validate:"required_if=Enabled true,gt=0"`
+	Enabled     *bool         `yaml:"enabled,omitempty"`
+	MaxRequests int           `yaml:"max_requests,omitempty" validate:"required_if=Enabled true,gt=0"`
+	Duration    time.Duration `yaml:"duration,omitempty" validate:"required_if=Enabled true,gt=0"`
 }
 
 // Struct for service-specific settings
 type AuthConfig struct {
-	Enabled *bool  `yaml:"enabled"`
-	Key     string `yaml:"key" validate:"required_if=Enabled true"`
-	Value   string `yaml:"value" validate:"required_if=Enabled true"`
+	Enabled *bool  `yaml:"enabled,omitempty"`
+	Key     string `yaml:"key,omitempty" validate:"required_if=Enabled true"`
+	Value   string `yaml:"value,omitempty" validate:"required_if=Enabled true"`
 }
 
 // FirewallConfig contains common configuration fields
 type FirewallConfig struct {
-	IPAllowList        []string `yaml:"ip_allowlist" validate:"omitempty,dive,ip|cidr"`
-	IPBlockList        []string `yaml:"ip_blocklist" validate:"omitempty,dive,ip|cidr"`
-	UserAgentAllowlist []string `yaml:"user_agent_allowlist" validate:"omitempty"`
-	UserAgentBlocklist []string `yaml:"user_agent_blocklist" validate:"omitempty"`
+	IPAllowList        []string `yaml:"ip_allowlist,omitempty" validate:"omitempty,dive,ip|cidr"`
+	IPBlockList        []string `yaml:"ip_blocklist,omitempty" validate:"omitempty,dive,ip|cidr"`
+	UserAgentAllowlist []string `yaml:"user_agent_allowlist,omitempty" validate:"omitempty"`
+	UserAgentBlocklist []string `yaml:"user_agent_blocklist,omitempty" validate:"omitempty"`
 }
 
 // CacheConfig defines caching behavior
 type CacheConfig struct {
-	Enabled  *bool         `yaml:"enabled"`
-	Duration time.Duration `yaml:"duration" validate:"required_if=Enabled true,gt=0"`
+	Enabled  *bool         `yaml:"enabled,omitempty"`
+	Duration time.Duration `yaml:"duration,omitempty" validate:"required_if=Enabled true,gt=0"`
+}
+
+// TargetConfig is a single upstream instance of a service
+type TargetConfig struct {
+	URL    string `yaml:"url,omitempty" validate:"required,url"`
+	Weight int    `yaml:"weight,omitempty" validate:"omitempty,gt=0"`
+}
+
+// LoadBalancingConfig selects how requests are spread across targets
+type LoadBalancingConfig struct {
+	Algorithm string `yaml:"algorithm,omitempty" validate:"omitempty,oneof=round_robin weighted_round_robin least_connections random_two_choices consistent_hash"`
+	HashOn    string `yaml:"hash_on,omitempty" validate:"omitempty,oneof=header cookie ip"`
+	HashKey   string `yaml:"hash_key,omitempty" validate:"required_if=HashOn header,required_if=HashOn cookie"`
+}
+
+// HealthCheckConfig defines active health checks against every target
+type HealthCheckConfig struct {
+	Enabled            *bool         `yaml:"enabled,omitempty"`
+	Path               string        `yaml:"path,omitempty"`
+	ExpectedStatus     int           `yaml:"expected_status,omitempty" validate:"omitempty,gte=100,lte=599"`
+	Interval           time.Duration `yaml:"interval,omitempty" validate:"omitempty,gt=0"`
+	Timeout            time.Duration `yaml:"timeout,omitempty" validate:"omitempty,gt=0"`
+	HealthyThreshold   int           `yaml:"healthy_threshold,omitempty" validate:"omitempty,gt=0"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold,omitempty" validate:"omitempty,gt=0"`
+}
+
+// OutlierDetectionConfig defines passive health checking based on the
+// responses of real traffic
+type OutlierDetectionConfig struct {
+	Enabled           *bool         `yaml:"enabled,omitempty"`
+	ConsecutiveErrors int           `yaml:"consecutive_errors,omitempty" validate:"omitempty,gt=0"`
+	BaseEjectionTime  time.Duration `yaml:"base_ejection_time,omitempty" validate:"omitempty,gt=0"`
+	MaxEjectionTime   time.Duration `yaml:"max_ejection_time,omitempty" validate:"omitempty,gt=0"`
+	MaxEjectedPercent int           `yaml:"max_ejected_percent,omitempty" validate:"omitempty,gt=0,lte=100"`
+}
+
+// CircuitBreakerConfig stops sending traffic to a failing service for a while
+type CircuitBreakerConfig struct {
+	Enabled          *bool         `yaml:"enabled,omitempty"`
+	FailureRatio     float64       `yaml:"failure_ratio,omitempty" validate:"omitempty,gt=0,lte=1"`
+	MinimumRequests  int           `yaml:"minimum_requests,omitempty" validate:"omitempty,gt=0"`
+	Window           time.Duration `yaml:"window,omitempty" validate:"omitempty,gt=0"`
+	OpenDuration     time.Duration `yaml:"open_duration,omitempty" validate:"omitempty,gt=0"`
+	HalfOpenRequests int           `yaml:"half_open_requests,omitempty" validate:"omitempty,gt=0"`
+}
+
+// RetryConfig defines when and how failed upstream requests are retried
+type RetryConfig struct {
+	Enabled       *bool         `yaml:"enabled,omitempty"`
+	MaxAttempts   int           `yaml:"max_attempts,omitempty" validate:"omitempty,gt=1"`
+	RetryOn       []string      `yaml:"retry_on,omitempty" validate:"omitempty,dive,oneof=connect_error timeout"`
+	StatusCodes   []int         `yaml:"status_codes,omitempty" validate:"omitempty,dive,gte=100,lte=599"`
+	Methods       []string      `yaml:"methods,omitempty" validate:"omitempty,dive,required"`
+	BackoffBase   time.Duration `yaml:"backoff_base,omitempty" validate:"omitempty,gt=0"`
+	BackoffMax    time.Duration `yaml:"backoff_max,omitempty" validate:"omitempty,gt=0"`
+	BudgetPercent int           `yaml:"budget_percent,omitempty" validate:"omitempty,gt=0,lte=100"`
+	MinRetries    int           `yaml:"min_retries,omitempty" validate:"omitempty,gt=0"`
+}
+
+// TimeoutConfig bounds how long the gateway waits on an upstream
+type TimeoutConfig struct {
+	Connect        time.Duration `yaml:"connect,omitempty" validate:"omitempty,gt=0"`
+	ResponseHeader time.Duration `yaml:"response_header,omitempty" validate:"omitempty,gt=0"`
+	Total          time.Duration `yaml:"total,omitempty" validate:"omitempty,gt=0"`
+}
+
+// ForwardedHeadersConfig selects the proxy headers added to upstream
+// requests. X-Forwarded-* and X-Request-ID are sent by default, the RFC 7239
+// Forwarded header only when enabled.
+type ForwardedHeadersConfig struct {
+	XForwardedFor    *bool `yaml:"x_forwarded_for,omitempty"`
+	XForwardedProto  *bool `yaml:"x_forwarded_proto,omitempty"`
+	XForwardedHost   *bool `yaml:"x_forwarded_host,omitempty"`
+	XForwardedPrefix *bool `yaml:"x_forwarded_prefix,omitempty"`
+	Forwarded        *bool `yaml:"forwarded,omitempty"`
+	RequestID        *bool `yaml:"request_id,omitempty"`
+}
+
+// ClientCertHeadersConfig names the headers carrying the verified client
+// certificate identity to the upstream
+type ClientCertHeadersConfig struct {
+	Subject     string `yaml:"subject,omitempty"`
+	SAN         string `yaml:"san,omitempty"`
+	Fingerprint string `yaml:"fingerprint,omitempty"`
+}
+
+// MTLSConfig authenticates callers of a service by client certificate
+type MTLSConfig struct {
+	Enabled *bool  `yaml:"enabled,omitempty"`
+	CAFile  string `yaml:"ca_file,omitempty" validate:"required_if=Enabled true"`
+	// Mode is required (default) or optional; optional lets requests
+	// without a certificate through but still rejects invalid ones
+	Mode            string                   `yaml:"mode,omitempty" validate:"omitempty,oneof=required optional"`
+	AllowedSubjects []string                 `yaml:"allowed_subjects,omitempty"`
+	AllowedSANs     []string                 `yaml:"allowed_sans,omitempty"`
+	Headers         *ClientCertHeadersConfig `yaml:"headers,omitempty"`
+}
+
+// UpstreamTLSConfig defines how the gateway connects to https upstreams
+type UpstreamTLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty" validate:"required_with=KeyFile"`
+	KeyFile            string `yaml:"key_file,omitempty" validate:"required_with=CertFile"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	MinVersion         string `yaml:"min_version,omitempty" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+}
+
+// InheritMode controls how a service setting combines with its global counterpart
+type InheritMode string
+
+const (
+	// InheritOverride uses the service value when set, otherwise the global one
+	InheritOverride InheritMode = "override"
+	// InheritAppend combines global and service list entries
+	InheritAppend InheritMode = "append"
+	// InheritNone ignores the global value entirely
+	InheritNone InheritMode = "none"
+)
+
+// InheritConfig selects the inherit mode per setting. IP lists and the user
+// agent blocklist default to append; the user agent allowlist, rate limits
+// and caching default to override.
+type InheritConfig struct {
+	IPAllowList        InheritMode `yaml:"ip_allowlist,omitempty" validate:"omitempty,oneof=override append none"`
+	IPBlockList        InheritMode `yaml:"ip_blocklist,omitempty" validate:"omitempty,oneof=override append none"`
+	UserAgentAllowlist InheritMode `yaml:"user_agent_allowlist,omitempty" validate:"omitempty,oneof=override append none"`
+	UserAgentBlocklist InheritMode `yaml:"user_agent_blocklist,omitempty" validate:"omitempty,oneof=override append none"`
+	RateLimit          InheritMode `yaml:"rate_limit,omitempty" validate:"omitempty,oneof=override none"`
+	Cache              InheritMode `yaml:"cache,omitempty" validate:"omitempty,oneof=override none"`
 }
 
 // ServiceConfig extends BaseConfig with service-specific settings
 type ServiceConfig struct {
-	FirewallConfig `yaml:",inline"`
-	Name           string           `yaml:"name"`
-	URL            string           `yaml:"url" validate:"required,url"`
-	Auth           *AuthConfig      `yaml:"auth"`
-	RateLimit      *RateLimitConfig `yaml:"rate_limit"`
-	Cache          *CacheConfig     `yaml:"cache"`
+	FirewallConfig   `yaml:",inline"`
+	Name             string                  `yaml:"name,omitempty"`
+	URL              string                  `yaml:"url,omitempty" validate:"required_without=Targets,excluded_with=Targets,omitempty,url"`
+	Targets          []TargetConfig          `yaml:"targets,omitempty" validate:"required_without=URL,omitempty,dive"`
+	LoadBalancing    *LoadBalancingConfig    `yaml:"load_balancing,omitempty"`
+	HealthCheck      *HealthCheckConfig      `yaml:"health_check,omitempty"`
+	OutlierDetection *OutlierDetectionConfig `yaml:"outlier_detection,omitempty"`
+	CircuitBreaker   *CircuitBreakerConfig   `yaml:"circuit_breaker,omitempty"`
+	Retry            *RetryConfig            `yaml:"retry,omitempty"`
+	Timeouts         *TimeoutConfig          `yaml:"timeouts,omitempty"`
+	ForwardedHeaders *ForwardedHeadersConfig `yaml:"forwarded_headers,omitempty"`
+	MTLS             *MTLSConfig             `yaml:"mtls,omitempty"`
+	UpstreamTLS      *UpstreamTLSConfig      `yaml:"upstream_tls,omitempty"`
+	Auth             *AuthConfig             `yaml:"auth,omitempty"`
+	RateLimit        *RateLimitConfig        `yaml:"rate_limit,omitempty"`
+	Cache            *CacheConfig            `yaml:"cache,omitempty"`
+	Inherit          *InheritConfig          `yaml:"inherit,omitempty"`
+}
+
+// UpstreamTargets returns the configured targets, treating url as a
+// single-target shorthand
+func (s *ServiceConfig) UpstreamTargets() []TargetConfig {
+	if len(s.Targets) > 0 {
+		return s.Targets
+	}
+	return []TargetConfig{{URL: s.URL, Weight: 1}}
 }
 
 // GlobalConfig extends BaseConfig with global settings
 type GlobalConfig struct {
 	FirewallConfig `yaml:",inline"`
-	Logging        *bool            `yaml:"logging"`
-	Cache          *CacheConfig     `yaml:"cache"`
-	RateLimit      *RateLimitConfig `yaml:"rate_limit"`
+	Logging        *bool            `yaml:"logging,omitempty"`
+	Cache          *CacheConfig     `yaml:"cache,omitempty"`
+	RateLimit      *RateLimitConfig `yaml:"rate_limit,omitempty"`
+	TrustedProxies []string         `yaml:"trusted_proxies,omitempty" validate:"omitempty,dive,ip|cidr"`
+}
+
+// CertificateConfig is a PEM certificate chain and its private key
+type CertificateConfig struct {
+	CertFile string `yaml:"cert_file,omitempty" validate:"required"`
+	KeyFile  string `yaml:"key_file,omitempty" validate:"required"`
+}
+
+// TLSConfig defines HTTPS termination on the gateway listener. The
+// certificate matching the client's SNI server name is served.
+type TLSConfig struct {
+	Enabled      *bool               `yaml:"enabled,omitempty"`
+	Certificates []CertificateConfig `yaml:"certificates,omitempty" validate:"required_if=Enabled true,omitempty,dive"`
+	MinVersion   string              `yaml:"min_version,omitempty" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+	CipherSuites []string            `yaml:"cipher_suites,omitempty"`
 }
 
 // Root configuration struct
 type MainConfig struct {
-	Services map[string]ServiceConfig `yaml:"services" validate:"required,dive"`
-	Global   *GlobalConfig            `yaml:"global"`
+	Include  []string                 `yaml:"include,omitempty"`
+	Services map[string]ServiceConfig `yaml:"services,omitempty" validate:"required,dive"`
+	Global   *GlobalConfig            `yaml:"global,omitempty"`
+	TLS      *TLSConfig               `yaml:"tls,omitempty"`
 }
 
+// DefaultConfigPath is used when neither --config nor CONFIG_PATH is set
+const DefaultConfigPath = "config/main.yaml"
+
 var (
-	Main MainConfig
+	// configPath is the base config file the active configuration was loaded from
+	configPath = DefaultConfigPath
 )
 
-// LoadConfig loads the main configuration from the specified path
-func LoadConfig() error {
-	// Load .env file if it exists
+// LoadEnv loads the .env file if it exists
+func LoadEnv() {
 	if err := godotenv.Load(); err != nil {
 		if pkgConfig.GetEnv("GO_ENV") != "production" {
 			log.Println("Warning: Failed to load .env file")
 		}
 	}
+}
 
-	// Read config file
-	data, err := os.ReadFile("config/main.yaml")
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+// ResolvePath returns path, falling back to CONFIG_PATH and then
+// DefaultConfigPath when it is empty
+func ResolvePath(path string) string {
+	if path == "" {
+		return pkgConfig.GetEnvOrDefault("CONFIG_PATH", DefaultConfigPath)
 	}
+	return path
+}
 
-	// Parse YAML
-	var config MainConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
-	}
+// LoadConfig loads the main configuration from path, falling back to
+// CONFIG_PATH and then DefaultConfigPath when path is empty
+func LoadConfig(path string) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
 
-	// Validate config
-	validate := validator.New()
-	if err := validate.Struct(&config); err != nil {
-		return fmt.Errorf("config validation failed: %w", err)
-	}
+	configPath = ResolvePath(path)
 
-	// Assign service names from keys in the map
-	for name, service := range config.Services {
-		service.Name = name
-		config.Services[name] = service
+	result, err := readConfig(configPath)
+	if err != nil {
+		return err
 	}
+	logWarnings(result.warnings)
 
 	// Store config globally
-	Main = config
+	snapshot := newSnapshot(result.config)
+	activate(snapshot)
+
+	log.Printf("Config loaded successfully from %s (version %d)", configPath, snapshot.Version())
+	return nil
+}
+
+// ReloadConfig re-reads and re-validates the config files and swaps them in.
+// On failure the previously loaded configuration stays active.
+func ReloadConfig() error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	result, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+	logWarnings(result.warnings)
+
+	snapshot := newSnapshot(result.config)
+	activate(snapshot)
 
-	log.Println("Config loaded successfully from config/main.yaml")
+	log.Printf("Config reloaded successfully from %s (version %d)", configPath, snapshot.Version())
 	return nil
 }
 
-// GetConfig returns the current configuration
+// ValidateFile parses and validates the configuration at path without
+// activating it. It returns the warnings found; errors are reported as a
+// *ValidationError when the file parses, or as a plain error otherwise.
+func ValidateFile(path string) ([]Issue, error) {
+	result, err := readConfig(ResolvePath(path))
+	if err != nil {
+		return nil, err
+	}
+	return result.warnings, nil
+}
+
+func logWarnings(warnings []Issue) {
+	for _, warning := range warnings {
+		log.Printf("Config %s", warning)
+	}
+}
+
+// GetConfig returns a copy of the current configuration. Request handlers
+// should use the snapshot pinned to the request instead.
 func GetConfig() MainConfig {
-	return Main
+	return *Current().Config()
 }
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// placeholderPattern matches ${NAME}, ${NAME:-default} and ${file:/path},
+// optionally preceded by an escaping '$' ($${...} yields a literal ${...})
+var placeholderPattern = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// envNamePattern restricts placeholder names to valid environment variables
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolate expands environment variable and secret file placeholders in
+// the scalar values of a parsed document. Expanding after parsing means a
+// value can never change the document structure, whatever it contains.
+func interpolate(node *yaml.Node) error {
+	var errs []error
+	interpolateNode(node, &errs)
+	return errors.Join(errs...)
+}
+
+// interpolateNode expands the scalars below node, skipping mapping keys and
+// aliases, whose anchored value is expanded where it is defined
+func interpolateNode(node *yaml.Node, errs *[]error) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			interpolateNode(child, errs)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			interpolateNode(node.Content[i+1], errs)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return
+		}
+		node.Value = placeholderPattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+			// Escaped placeholder, drop the leading '$' and keep the rest verbatim
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+
+			value, err := resolvePlaceholder(match[2 : len(match)-1])
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("line %d: %w", node.Line, err))
+				return match
+			}
+			return value
+		})
+
+		// Let plain scalars resolve to the type of their expanded value,
+		// e.g. a number or boolean; quoted scalars stay strings
+		if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Tag = ""
+		}
+	}
+}
+
+// resolvePlaceholder resolves the expression inside ${...}
+func resolvePlaceholder(expr string) (string, error) {
+	// Secret file reference
+	if path, ok := strings.CutPrefix(expr, "file:"); ok {
+		if path == "" {
+			return "", fmt.Errorf("placeholder ${%s} is missing a file path", expr)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+		}
+		// Secret files conventionally end with a newline that is not part of the value
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	// Environment variable with optional default
+	name, fallback, hasDefault := strings.Cut(expr, ":-")
+	if !envNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid placeholder ${%s}", expr)
+	}
+
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	if hasDefault {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set", name)
+}
+
+// containsPlaceholder reports whether data has any unescaped placeholder
+// outside of comment lines
+func containsPlaceholder(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			continue
+		}
+		for _, match := range placeholderPattern.FindAll(line, -1) {
+			if !bytes.HasPrefix(match, []byte("$$")) {
+				return true
+			}
+		}
+	}
+	return false
+}
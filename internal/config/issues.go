@@ -0,0 +1,328 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// Position locates a value in a config source file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String formats the position as file:line
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.File == "":
+		return fmt.Sprintf("line %d", p.Line)
+	default:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+}
+
+// Issue describes a single problem found in the configuration
+type Issue struct {
+	Path     string
+	Position Position
+	Message  string
+	Warning  bool
+}
+
+// String formats the issue as "file:line: path: message"
+func (i Issue) String() string {
+	var b strings.Builder
+	if pos := i.Position.String(); pos != "" {
+		b.WriteString(pos)
+		b.WriteString(": ")
+	}
+	if i.Warning {
+		b.WriteString("warning: ")
+	}
+	if i.Path != "" {
+		b.WriteString(i.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// ValidationError is returned when the configuration parses but is invalid.
+// Issues holds the errors followed by any warnings.
+type ValidationError struct {
+	Issues []Issue
+}
+
+// Errors returns the number of issues that are not warnings
+func (e *ValidationError) Errors() int {
+	count := 0
+	for _, issue := range e.Issues {
+		if !issue.Warning {
+			count++
+		}
+	}
+	return count
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		messages[i] = issue.String()
+	}
+	return "config validation failed: " + strings.Join(messages, "; ")
+}
+
+// positions maps config paths such as services.mailer.ip_allowlist[0] to
+// the place they were defined
+type positions map[string]Position
+
+// index records the position of every value below node. Later calls
+// override earlier ones so overlays win over the base file.
+func (p positions) index(node *yaml.Node, file, path string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := joinPath(path, key.Value)
+			p[child] = Position{File: file, Line: key.Line, Column: key.Column}
+			p.index(value, file, child)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := path + "[" + strconv.Itoa(i) + "]"
+			p[child] = Position{File: file, Line: item.Line, Column: item.Column}
+			p.index(item, file, child)
+		}
+	}
+}
+
+// lookup returns the position of path, or of its closest defined parent
+func (p positions) lookup(path, fallbackFile string) Position {
+	for path != "" {
+		if pos, ok := p[path]; ok {
+			return pos
+		}
+		idx := strings.LastIndexAny(path, ".[")
+		if idx < 0 {
+			break
+		}
+		path = path[:idx]
+	}
+	return Position{File: fallbackFile}
+}
+
+// joinPath appends a mapping key to a dotted config path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// inlineFieldName marks embedded structs in validator namespaces so they can
+// be dropped from the resulting config path
+const inlineFieldName = "~inline"
+
+// newValidator returns a validator that reports fields by their YAML names
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		tag := field.Tag.Get("yaml")
+		name, options, _ := strings.Cut(tag, ",")
+		if strings.Contains(options, "inline") {
+			return inlineFieldName
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return validate
+}
+
+// validationIssues converts validator errors into issues with YAML paths
+func validationIssues(err error, pos positions, fallbackFile string) []Issue {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []Issue{{Position: Position{File: fallbackFile}, Message: err.Error()}}
+	}
+
+	issues := make([]Issue, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		path := namespaceToPath(fieldErr.Namespace())
+		issues = append(issues, Issue{
+			Path:     path,
+			Position: pos.lookup(path, fallbackFile),
+			Message:  validationMessage(fieldErr),
+		})
+	}
+	return issues
+}
+
+// decodeLinePattern splits a YAML decode error such as
+// "line 4: cannot unmarshal !!int `5` into time.Duration" into its line and
+// message, and captures the tag and the (possibly truncated) value
+var decodeLinePattern = regexp.MustCompile("^line (\\d+): (.*?(?:unmarshal (!!\\w+)(?: `([^`]*)`)?.*)?)$")
+
+// decodeIssues converts YAML decode errors on the merged document into
+// issues with config paths. Decode errors only carry a line number, which
+// may exist in several merged files, so the offending node is looked up by
+// its line and value.
+func decodeIssues(err error, root *yaml.Node, pos positions, fallbackFile string) []Issue {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return []Issue{{Position: Position{File: fallbackFile}, Message: err.Error()}}
+	}
+
+	issues := make([]Issue, 0, len(typeErr.Errors))
+	for _, message := range typeErr.Errors {
+		match := decodeLinePattern.FindStringSubmatch(message)
+		if match == nil {
+			issues = append(issues, Issue{Position: Position{File: fallbackFile}, Message: message})
+			continue
+		}
+
+		line, _ := strconv.Atoi(match[1])
+		path, found := findNode(root, "", line, nodeKind(match[3]), match[4])
+		issue := Issue{Path: path, Position: pos.lookup(path, fallbackFile), Message: match[2]}
+		if !found {
+			issue.Position = Position{File: fallbackFile, Line: line}
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// nodeKind returns the node kind of a decode error tag such as !!seq, or 0
+// when the error has no tag
+func nodeKind(tag string) yaml.Kind {
+	switch tag {
+	case "":
+		return 0
+	case "!!seq":
+		return yaml.SequenceNode
+	case "!!map":
+		return yaml.MappingNode
+	default:
+		return yaml.ScalarNode
+	}
+}
+
+// findNode returns the config path of the first value node below node that
+// is on line, of kind unless it is 0, and whose value matches the value
+// quoted in a decode error, which the decoder truncates to seven characters
+// followed by "..."
+func findNode(node *yaml.Node, path string, line int, kind yaml.Kind, value string) (string, bool) {
+	if node.Line == line && path != "" && (kind == 0 || node.Kind == kind) {
+		prefix, truncated := strings.CutSuffix(value, "...")
+		if value == "" || node.Value == value || (truncated && strings.HasPrefix(node.Value, prefix)) {
+			return path, true
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if found, ok := findNode(node.Content[i+1], joinPath(path, node.Content[i].Value), line, kind, value); ok {
+				return found, true
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if found, ok := findNode(item, path+"["+strconv.Itoa(i)+"]", line, kind, value); ok {
+				return found, true
+			}
+		}
+	}
+	return "", false
+}
+
+// namespaceToPath turns MainConfig.services[mailer].~inline.ip_allowlist[0]
+// into services.mailer.ip_allowlist[0]
+func namespaceToPath(namespace string) string {
+	// Drop the root struct name
+	if _, rest, ok := strings.Cut(namespace, "."); ok {
+		namespace = rest
+	} else {
+		return ""
+	}
+
+	var path string
+	for _, segment := range strings.Split(namespace, ".") {
+		if segment == inlineFieldName {
+			continue
+		}
+
+		name, index, hasIndex := strings.Cut(segment, "[")
+		path = joinPath(path, name)
+		if hasIndex {
+			index = strings.TrimSuffix(index, "]")
+			if _, err := strconv.Atoi(index); err == nil {
+				path += "[" + index + "]"
+			} else {
+				path = joinPath(path, index)
+			}
+		}
+	}
+	return path
+}
+
+// validationMessage renders a validator error in plain language
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		field, value, _ := strings.Cut(fieldErr.Param(), " ")
+		return fmt.Sprintf("is required when %s is %s", yamlFieldName(field), value)
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", yamlFieldName(fieldErr.Param()))
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", yamlFieldName(fieldErr.Param()))
+	case "excluded_with":
+		return fmt.Sprintf("cannot be combined with %s", yamlFieldName(fieldErr.Param()))
+	case "url":
+		return fmt.Sprintf("must be a valid URL, got %q", fieldErr.Value())
+	case "ip|cidr":
+		return fmt.Sprintf("must be an IP address or CIDR, got %q", fieldErr.Value())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param())
+	case "gte":
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "lte":
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s], got %q", fieldErr.Param(), fieldErr.Value())
+	default:
+		return fmt.Sprintf("failed the '%s' rule", fieldErr.Tag())
+	}
+}
+
+// yamlFieldName converts a Go field name used in validate tag parameters
+// (e.g. RateLimit) into its YAML spelling (rate_limit)
+func yamlFieldName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			// Start a new word unless we are inside an acronym such as URL
+			if i > 0 && (!unicode.IsUpper(rune(name[i-1])) || (i+1 < len(name) && unicode.IsLower(rune(name[i+1])))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
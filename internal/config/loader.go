@@ -0,0 +1,341 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	pkgConfig "github.com/kerimovok/go-pkg-utils/config"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultIncludePatterns are loaded in addition to the include list, relative
+// to the directory of the base config file
+var defaultIncludePatterns = []string{"services.d/*.yaml", "services.d/*.yml"}
+
+// configFiles returns the base config file followed by its optional
+// environment overlay, e.g. main.yaml and main.production.yaml
+func configFiles(path string) []string {
+	env := pkgConfig.GetEnvOrDefault("GO_ENV", "development")
+	ext := filepath.Ext(path)
+	overlay := strings.TrimSuffix(path, ext) + "." + env + ext
+	return []string{path, overlay}
+}
+
+// includeFiles expands the default and configured include patterns into a
+// deduplicated list of files. Relative patterns are resolved against the
+// directory of the base config file.
+func includeFiles(path string, patterns []string) ([]string, error) {
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	var files []string
+
+	for i, pattern := range append(append([]string{}, defaultIncludePatterns...), patterns...) {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %s: %w", pattern, err)
+		}
+
+		// Explicitly listed files must exist, globs may match nothing
+		isExplicit := i >= len(defaultIncludePatterns) && !strings.ContainsAny(pattern, "*?[")
+		if len(matches) == 0 && isExplicit {
+			return nil, fmt.Errorf("included config file %s does not exist", pattern)
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// sourceFiles lists every file the configuration at path is built from,
+// including optional files that do not exist yet and TLS certificates
+func sourceFiles(path string) []string {
+	cfg := Current().Config()
+	files := configFiles(path)
+	if includes, err := includeFiles(path, cfg.Include); err == nil {
+		files = append(files, includes...)
+	}
+
+	// Reload when certificates are renewed on disk
+	if cfg.TLS != nil {
+		for _, certificate := range cfg.TLS.Certificates {
+			files = append(files, certificate.CertFile, certificate.KeyFile)
+		}
+	}
+	for _, service := range cfg.Services {
+		if service.MTLS != nil && service.MTLS.CAFile != "" {
+			files = append(files, service.MTLS.CAFile)
+		}
+		if upstreamTLS := service.UpstreamTLS; upstreamTLS != nil {
+			for _, file := range []string{upstreamTLS.CAFile, upstreamTLS.CertFile, upstreamTLS.KeyFile} {
+				if file != "" {
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files
+}
+
+// loadResult is a parsed and validated configuration along with the
+// warnings found while checking it
+type loadResult struct {
+	config   *MainConfig
+	warnings []Issue
+}
+
+// readConfig parses the base config file and its environment overlay,
+// deep-merges them, adds services from included files and validates the result
+func readConfig(path string) (*loadResult, error) {
+	pos := make(positions)
+
+	var merged *yaml.Node
+	for i, file := range configFiles(path) {
+		root, err := readYAMLFile(file)
+		if err != nil {
+			// Only the base file is mandatory
+			if i > 0 && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		// Skip empty documents
+		if root == nil {
+			continue
+		}
+		pos.index(root, file, "")
+
+		if merged == nil {
+			merged = root
+		} else {
+			merged = mergeNodes(merged, root)
+		}
+	}
+
+	if merged == nil {
+		merged = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+
+	if err := mergeIncludes(path, merged, pos); err != nil {
+		return nil, err
+	}
+
+	// Reject unknown keys unless strict decoding has been turned off
+	problems, warnings := strictIssues(unknownFields(merged, reflect.TypeOf(MainConfig{}), "", pos, path))
+
+	// Decode merged YAML. Type errors leave their fields unset, so they are
+	// reported together with the other problems instead of stopping here.
+	var config MainConfig
+	decodeFailed := make(map[string]bool)
+	if err := merged.Decode(&config); err != nil {
+		for _, issue := range decodeIssues(err, merged, pos, path) {
+			decodeFailed[issue.Path] = true
+			problems = append(problems, issue)
+		}
+	}
+
+	// Validate config, collecting struct and semantic problems together.
+	// Fields that failed to decode are not reported again.
+	validationProblems, validationWarnings := validateConfig(&config, pos, path)
+	for _, issue := range validationProblems {
+		if !decodeFailed[issue.Path] {
+			problems = append(problems, issue)
+		}
+	}
+	warnings = append(warnings, validationWarnings...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Issues: append(problems, warnings...)}
+	}
+
+	assignServiceNames(&config)
+
+	return &loadResult{config: &config, warnings: warnings}, nil
+}
+
+// strictIssues reports unknown keys as errors, or as warnings when
+// CONFIG_STRICT is false
+func strictIssues(issues []Issue) (problems, warnings []Issue) {
+	if pkgConfig.GetEnvBool("CONFIG_STRICT", true) {
+		return issues, nil
+	}
+	for _, issue := range issues {
+		issue.Warning = true
+		warnings = append(warnings, issue)
+	}
+	return nil, warnings
+}
+
+// validateConfig runs struct validation and semantic checks on a decoded
+// configuration, splitting the result into errors and warnings
+func validateConfig(config *MainConfig, pos positions, file string) (problems, warnings []Issue) {
+	if err := newValidator().Struct(config); err != nil {
+		problems = append(problems, validationIssues(err, pos, file)...)
+	}
+
+	for _, issue := range checkSemantics(config, pos, file) {
+		if issue.Warning {
+			warnings = append(warnings, issue)
+		} else {
+			problems = append(problems, issue)
+		}
+	}
+	return problems, warnings
+}
+
+// assignServiceNames sets each service's name from its key in the services map
+func assignServiceNames(config *MainConfig) {
+	for name, service := range config.Services {
+		service.Name = name
+		config.Services[name] = service
+	}
+}
+
+// readYAMLFile reads, parses and interpolates a single YAML file. It returns
+// a nil node for empty documents.
+func readYAMLFile(file string) (*yaml.Node, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", file, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", file, err)
+	}
+
+	// Expand ${ENV}, ${ENV:-default} and ${file:/path} placeholders
+	if err := interpolate(&doc); err != nil {
+		return nil, fmt.Errorf("failed to interpolate config file %s: %w", file, err)
+	}
+
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// mergeIncludes adds the services defined in included files to the merged
+// root node, rejecting services that are defined more than once
+func mergeIncludes(path string, root *yaml.Node, pos positions) error {
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse config file %s: root must be a mapping", path)
+	}
+
+	var patterns []string
+	if idx := mappingIndex(root, "include"); idx >= 0 {
+		if err := root.Content[idx+1].Decode(&patterns); err != nil {
+			return fmt.Errorf("failed to parse include list: %w", err)
+		}
+	}
+
+	files, err := includeFiles(path, patterns)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	services := mappingValue(root, "services")
+	if services == nil {
+		services = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "services"},
+			services,
+		)
+	}
+
+	// Track where each service was defined to report duplicates
+	sources := make(map[string]string)
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		sources[services.Content[i].Value] = path
+	}
+
+	for _, file := range files {
+		included, err := readYAMLFile(file)
+		if err != nil {
+			return err
+		}
+		if included == nil {
+			continue
+		}
+		pos.index(included, file, "")
+
+		if included.Kind != yaml.MappingNode {
+			return fmt.Errorf("included config file %s must be a mapping", file)
+		}
+		for i := 0; i+1 < len(included.Content); i += 2 {
+			if key := included.Content[i].Value; key != "services" {
+				return fmt.Errorf("included config file %s may only define services, found %q", file, key)
+			}
+		}
+
+		includedServices := mappingValue(included, "services")
+		if includedServices == nil {
+			continue
+		}
+
+		for i := 0; i+1 < len(includedServices.Content); i += 2 {
+			name := includedServices.Content[i].Value
+			if source, exists := sources[name]; exists {
+				return fmt.Errorf("service %q is defined in both %s and %s", name, source, file)
+			}
+			sources[name] = file
+			services.Content = append(services.Content, includedServices.Content[i], includedServices.Content[i+1])
+		}
+	}
+
+	return nil
+}
+
+// mergeNodes deep-merges overlay into base. Mappings are merged key by key;
+// any other node in the overlay (scalars, sequences) replaces the base node.
+func mergeNodes(base, overlay *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return overlay
+	}
+
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		if idx := mappingIndex(base, key.Value); idx >= 0 {
+			base.Content[idx+1] = mergeNodes(base.Content[idx+1], value)
+		} else {
+			base.Content = append(base.Content, key, value)
+		}
+	}
+
+	return base
+}
+
+// mappingIndex returns the index of key within a mapping node's content, or -1
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value node for key within a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if idx := mappingIndex(mapping, key); idx >= 0 {
+		return mapping.Content[idx+1]
+	}
+	return nil
+}
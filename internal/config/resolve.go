@@ -0,0 +1,49 @@
+package config
+
+// ResolveService applies the global settings to a service according to its
+// inherit modes and returns the effective service configuration. The
+// returned value shares no list storage with the inputs.
+func ResolveService(service ServiceConfig, global *GlobalConfig) ServiceConfig {
+	var inherit InheritConfig
+	if service.Inherit != nil {
+		inherit = *service.Inherit
+	}
+	if global == nil {
+		global = &GlobalConfig{}
+	}
+
+	resolved := service
+	resolved.IPAllowList = resolveList(service.IPAllowList, global.IPAllowList, inherit.IPAllowList, InheritAppend)
+	resolved.IPBlockList = resolveList(service.IPBlockList, global.IPBlockList, inherit.IPBlockList, InheritAppend)
+	resolved.UserAgentAllowlist = resolveList(service.UserAgentAllowlist, global.UserAgentAllowlist, inherit.UserAgentAllowlist, InheritOverride)
+	resolved.UserAgentBlocklist = resolveList(service.UserAgentBlocklist, global.UserAgentBlocklist, inherit.UserAgentBlocklist, InheritAppend)
+
+	if resolved.RateLimit == nil && inherit.RateLimit != InheritNone {
+		resolved.RateLimit = global.RateLimit
+	}
+	if resolved.Cache == nil && inherit.Cache != InheritNone {
+		resolved.Cache = global.Cache
+	}
+
+	return resolved
+}
+
+// resolveList combines a service list with the global list using mode, or
+// fallback when mode is not set
+func resolveList(service, global []string, mode, fallback InheritMode) []string {
+	if mode == "" {
+		mode = fallback
+	}
+
+	switch mode {
+	case InheritNone:
+		return append([]string(nil), service...)
+	case InheritAppend:
+		return append(append([]string(nil), global...), service...)
+	default:
+		if len(service) > 0 {
+			return append([]string(nil), service...)
+		}
+		return append([]string(nil), global...)
+	}
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationPattern matches Go duration strings such as 90s or 1h30m
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// cidrPattern loosely matches IPv4 and IPv6 CIDR notation
+const cidrPattern = `^[0-9a-fA-F:.]+/[0-9]{1,3}$`
+
+// Schema returns a JSON Schema (draft-07) describing the config file format,
+// derived from the config structs and their validate tags
+func Schema() map[string]any {
+	generator := &schemaGenerator{definitions: make(map[string]any)}
+	root := generator.structSchema(reflect.TypeOf(MainConfig{}))
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "API gateway configuration"
+	root["definitions"] = generator.definitions
+	return root
+}
+
+// schemaGenerator builds schemas for nested types, emitting named structs
+// once under definitions
+type schemaGenerator struct {
+	definitions map[string]any
+}
+
+// typeSchema returns the schema for t, referencing named structs by definition
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// The YAML decoder only accepts durations as strings
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, exists := g.definitions[t.Name()]; !exists {
+			// Reserve the name first so recursive types terminate
+			g.definitions[t.Name()] = map[string]any{}
+			g.definitions[t.Name()] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// structSchema returns the object schema for a struct, flattening inline
+// fields and translating validate tags into schema keywords
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	var conditions []any
+
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		names := yamlNames(t)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if strings.Contains(options, "inline") {
+				collect(field.Type)
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+
+			schema := g.typeSchema(field.Type)
+			fieldRules, elemRules := splitRules(field.Tag.Get("validate"))
+			for _, rule := range fieldRules {
+				tag, param, _ := strings.Cut(rule, "=")
+				switch tag {
+				case "required":
+					// Services may all come from included files, so only the
+					// merged configuration has to define them
+					if t == reflect.TypeOf(MainConfig{}) && field.Name == "Services" {
+						continue
+					}
+					required = append(required, name)
+				case "required_if":
+					if condition := requiredIf(name, param, names); condition != nil {
+						conditions = append(conditions, condition)
+					}
+				case "required_with":
+					if other, exists := names[param]; exists {
+						conditions = append(conditions, map[string]any{
+							"if":   map[string]any{"required": []string{other}},
+							"then": map[string]any{"required": []string{name}},
+						})
+					}
+				case "required_without":
+					if other, exists := names[param]; exists {
+						conditions = append(conditions, map[string]any{
+							"if":   map[string]any{"not": map[string]any{"required": []string{other}}},
+							"then": map[string]any{"required": []string{name}},
+						})
+					}
+				case "excluded_with":
+					if other, exists := names[param]; exists {
+						conditions = append(conditions, map[string]any{
+							"if":   map[string]any{"required": []string{other}},
+							"then": map[string]any{"not": map[string]any{"required": []string{name}}},
+						})
+					}
+				default:
+					applyRule(schema, field.Type, tag, param)
+				}
+			}
+			if len(elemRules) > 0 {
+				if items, ok := schema["items"].(map[string]any); ok {
+					for _, rule := range elemRules {
+						tag, param, _ := strings.Cut(rule, "=")
+						applyRule(items, field.Type.Elem(), tag, param)
+					}
+				}
+			}
+
+			properties[name] = schema
+		}
+	}
+	collect(t)
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if len(conditions) > 0 {
+		schema["allOf"] = conditions
+	}
+	return schema
+}
+
+// splitRules separates validate rules applying to a field from the rules
+// applying to its elements after "dive"
+func splitRules(tag string) (fieldRules, elemRules []string) {
+	if tag == "" {
+		return nil, nil
+	}
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == "dive" {
+			return rules[:i], rules[i+1:]
+		}
+	}
+	return rules, nil
+}
+
+// applyRule translates a single validate rule into schema keywords. Rules
+// without a JSON Schema equivalent are ignored.
+func applyRule(schema map[string]any, t reflect.Type, tag, param string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	isDuration := t == reflect.TypeOf(time.Duration(0))
+	isNumber := !isDuration && t.Kind() >= reflect.Int && t.Kind() <= reflect.Float64
+
+	switch tag {
+	case "url":
+		schema["format"] = "uri"
+	case "ip":
+		schema["anyOf"] = []any{map[string]any{"format": "ipv4"}, map[string]any{"format": "ipv6"}}
+	case "cidr":
+		schema["pattern"] = cidrPattern
+	case "ip|cidr":
+		schema["anyOf"] = []any{
+			map[string]any{"format": "ipv4"},
+			map[string]any{"format": "ipv6"},
+			map[string]any{"pattern": cidrPattern},
+		}
+	case "oneof":
+		values := make([]any, 0)
+		for _, value := range strings.Fields(param) {
+			values = append(values, value)
+		}
+		schema["enum"] = values
+	case "gt", "gte", "lt", "lte", "min", "max":
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		keyword := map[string]string{
+			"gt": "exclusiveMinimum", "gte": "minimum", "min": "minimum",
+			"lt": "exclusiveMaximum", "lte": "maximum", "max": "maximum",
+		}[tag]
+		switch {
+		case isNumber:
+			schema[keyword] = value
+		case t.Kind() == reflect.Slice && (tag == "min" || tag == "max"):
+			schema[tag+"Items"] = int(value)
+		case t.Kind() == reflect.String && (tag == "min" || tag == "max"):
+			schema[tag+"Length"] = int(value)
+		}
+	}
+}
+
+// requiredIf translates required_if=Field value into an if/then condition
+func requiredIf(name, param string, names map[string]string) map[string]any {
+	fields := strings.Fields(param)
+	if len(fields) != 2 {
+		return nil
+	}
+	other, exists := names[fields[0]]
+	if !exists {
+		return nil
+	}
+
+	var value any = fields[1]
+	if parsed, err := strconv.ParseBool(fields[1]); err == nil {
+		value = parsed
+	}
+
+	return map[string]any{
+		"if": map[string]any{
+			"properties": map[string]any{other: map[string]any{"const": value}},
+			"required":   []string{other},
+		},
+		"then": map[string]any{"required": []string{name}},
+	}
+}
+
+// yamlNames maps Go field names of a struct to their YAML keys
+func yamlNames(t reflect.Type) map[string]string {
+	names := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		names[field.Name] = name
+	}
+	return names
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+)
+
+// supportedSchemes lists upstream URL schemes the proxy can forward to
+var supportedSchemes = map[string]bool{"http": true, "https": true}
+
+// checkSemantics runs checks the struct validation tags cannot express.
+// Problems that break request handling are errors; suspicious but workable
+// settings are reported as warnings.
+func checkSemantics(config *MainConfig, pos positions, fallbackFile string) []Issue {
+	var issues []Issue
+	add := func(path, message string, warning bool) {
+		issues = append(issues, Issue{
+			Path:     path,
+			Position: pos.lookup(path, fallbackFile),
+			Message:  message,
+			Warning:  warning,
+		})
+	}
+
+	if config.Global != nil {
+		global := config.Global.FirewallConfig
+		checkFirewall("global", global, global.IPBlockList, add)
+	}
+
+	checkTLS(config.TLS, add)
+
+	// Iterate in a stable order so output is deterministic
+	names := make([]string, 0, len(config.Services))
+	for name := range config.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		service := config.Services[name]
+		path := "services." + name
+
+		for i, target := range service.Targets {
+			checkUpstreamURL(fmt.Sprintf("%s.targets[%d].url", path, i), target.URL, add)
+		}
+		checkUpstreamURL(path+".url", service.URL, add)
+
+		if outlier := service.OutlierDetection; outlier != nil {
+			if outlier.MaxEjectionTime > 0 && outlier.MaxEjectionTime < outlier.BaseEjectionTime {
+				add(path+".outlier_detection.max_ejection_time", "must not be shorter than base_ejection_time", false)
+			}
+		}
+		if retry := service.Retry; retry != nil {
+			if retry.BackoffMax > 0 && retry.BackoffMax < retry.BackoffBase {
+				add(path+".retry.backoff_max", "must not be shorter than backoff_base", false)
+			}
+		}
+
+		checkMTLS(path+".mtls", service.MTLS, config.TLS, add)
+		checkUpstreamTLS(path+".upstream_tls", &service, add)
+
+		// Compare against the effective blocklist, which may include global entries
+		effective := ResolveService(service, config.Global)
+		checkFirewall(path, service.FirewallConfig, effective.IPBlockList, add)
+	}
+
+	return issues
+}
+
+// checkTLS loads every certificate so broken files are rejected before they
+// are activated, and checks the cipher suite names
+func checkTLS(settings *TLSConfig, add func(path, message string, warning bool)) {
+	if settings == nil || settings.Enabled == nil || !*settings.Enabled {
+		return
+	}
+
+	for i, certificate := range settings.Certificates {
+		if _, err := certificate.Load(); err != nil {
+			add(fmt.Sprintf("tls.certificates[%d]", i), err.Error(), false)
+		}
+	}
+
+	for i, name := range settings.CipherSuites {
+		if _, ok := CipherSuite(name); !ok {
+			add(fmt.Sprintf("tls.cipher_suites[%d]", i), fmt.Sprintf("unknown or insecure cipher suite %q", name), false)
+		}
+	}
+	if len(settings.CipherSuites) > 0 && settings.MinVersion == "1.3" {
+		add("tls.cipher_suites", "cipher suites are not configurable for TLS 1.3 and have no effect", true)
+	}
+}
+
+// checkMTLS loads the CA bundle of a service and checks its identity patterns
+func checkMTLS(path string, settings *MTLSConfig, tlsSettings *TLSConfig, add func(path, message string, warning bool)) {
+	if settings == nil || settings.Enabled == nil || !*settings.Enabled {
+		return
+	}
+
+	if settings.CAFile != "" {
+		if _, err := LoadCertPool(settings.CAFile); err != nil {
+			add(path+".ca_file", err.Error(), false)
+		}
+	}
+	checkPatterns(path+".allowed_subjects", settings.AllowedSubjects, add)
+	checkPatterns(path+".allowed_sans", settings.AllowedSANs, add)
+
+	if tlsSettings == nil || tlsSettings.Enabled == nil || !*tlsSettings.Enabled {
+		add(path, "client certificates are only available when tls is enabled", true)
+	}
+}
+
+// checkUpstreamTLS loads the CA bundle and client certificate used towards
+// the upstream and warns about settings that weaken or do not affect it
+func checkUpstreamTLS(path string, service *ServiceConfig, add func(path, message string, warning bool)) {
+	settings := service.UpstreamTLS
+	if settings == nil {
+		return
+	}
+
+	loadable := *settings
+	if (loadable.CertFile == "") != (loadable.KeyFile == "") {
+		// A lone cert_file or key_file is already reported by validation
+		loadable.CertFile, loadable.KeyFile = "", ""
+	}
+	if _, err := loadable.ClientTLSConfig(); err != nil {
+		add(path, err.Error(), false)
+	}
+	if settings.InsecureSkipVerify {
+		add(path+".insecure_skip_verify", "upstream certificates are not verified", true)
+	}
+
+	for _, target := range service.UpstreamTargets() {
+		if upstream, err := url.Parse(target.URL); err == nil && upstream.Scheme == "https" {
+			return
+		}
+	}
+	add(path, "has no effect without an https upstream", true)
+}
+
+// checkUpstreamURL rejects upstream URLs the proxy cannot forward to
+func checkUpstreamURL(path, rawURL string, add func(path, message string, warning bool)) {
+	// Empty URLs and URLs without a scheme are already rejected by the url tag
+	upstream, err := url.Parse(rawURL)
+	if rawURL == "" || err != nil || upstream.Scheme == "" {
+		return
+	}
+
+	if !supportedSchemes[upstream.Scheme] {
+		add(path, fmt.Sprintf("unsupported upstream scheme %q, expected http or https", upstream.Scheme), false)
+	} else if upstream.Host == "" {
+		add(path, "upstream URL has no host", false)
+	}
+}
+
+// checkFirewall warns about allowlist entries that are fully covered by an
+// entry of the effective blocklist, which may include inherited global
+// entries. Invalid entries are already reported by the ip|cidr tag.
+func checkFirewall(path string, firewall FirewallConfig, effectiveBlockList []string, add func(path, message string, warning bool)) {
+	allow := parseNetworks(firewall.IPAllowList)
+	block := parseNetworks(effectiveBlockList)
+
+	for i, allowed := range allow {
+		if allowed == nil {
+			continue
+		}
+		for j, blocked := range block {
+			if blocked != nil && containsNetwork(blocked, allowed) {
+				add(fmt.Sprintf("%s.ip_allowlist[%d]", path, i), fmt.Sprintf("allowlist entry %s is blocked by blocklist entry %s", firewall.IPAllowList[i], effectiveBlockList[j]), true)
+				break
+			}
+		}
+	}
+}
+
+// parseNetworks parses IP and CIDR entries. Invalid entries are returned as
+// nil so indexes still match the config.
+func parseNetworks(entries []string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(entries))
+	for i, entry := range entries {
+		if network, err := ParseNetwork(entry); err == nil {
+			networks[i] = network
+		}
+	}
+	return networks
+}
+
+// ParseNetwork parses an IP address or CIDR into a network. Single addresses
+// become a /32 (IPv4) or /128 (IPv6) network.
+func ParseNetwork(entry string) (*net.IPNet, error) {
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address or CIDR %q", entry)
+	}
+	return network, nil
+}
+
+// containsNetwork reports whether outer fully contains inner
+func containsNetwork(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is an immutable, versioned view of the configuration. A new
+// snapshot is created on every successful load; existing ones are never
+// modified, so a request can hold on to one for its whole lifetime.
+type Snapshot struct {
+	version  uint64
+	loadedAt time.Time
+	config   *MainConfig
+}
+
+var (
+	// current holds the active snapshot and is swapped atomically on reload
+	current atomic.Pointer[Snapshot]
+	// lastVersion is the version assigned to the most recent snapshot
+	lastVersion atomic.Uint64
+
+	// listeners are notified of every activated snapshot, guarded by updateMu
+	listeners []func(*Snapshot)
+)
+
+// newSnapshot wraps a validated config into the next versioned snapshot
+func newSnapshot(config *MainConfig) *Snapshot {
+	return &Snapshot{
+		version:  lastVersion.Add(1),
+		loadedAt: time.Now(),
+		config:   config,
+	}
+}
+
+// Version returns the monotonically increasing snapshot version
+func (s *Snapshot) Version() uint64 {
+	return s.version
+}
+
+// LoadedAt returns the time the snapshot was created
+func (s *Snapshot) LoadedAt() time.Time {
+	return s.loadedAt
+}
+
+// Config returns the configuration held by the snapshot. Callers must treat
+// it as read-only.
+func (s *Snapshot) Config() *MainConfig {
+	return s.config
+}
+
+// Current returns the active configuration snapshot
+func Current() *Snapshot {
+	if snapshot := current.Load(); snapshot != nil {
+		return snapshot
+	}
+	return &Snapshot{config: &MainConfig{}}
+}
+
+// OnChange registers fn to be called with every newly activated snapshot.
+// Listeners run synchronously in activation order and must not block.
+func OnChange(fn func(*Snapshot)) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	listeners = append(listeners, fn)
+}
+
+// activate makes snapshot the current one and notifies listeners. Callers
+// must hold updateMu.
+func activate(snapshot *Snapshot) {
+	current.Store(snapshot)
+	for _, fn := range listeners {
+		fn(snapshot)
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unknownFields walks node alongside the Go type it decodes into and reports
+// every mapping key that has no matching field. Unlike yaml.Decoder's
+// KnownFields it reports the full config path and the file of each key.
+func unknownFields(node *yaml.Node, t reflect.Type, path string, pos positions, fallbackFile string) []Issue {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var issues []Issue
+	switch {
+	case node.Kind == yaml.AliasNode && node.Alias != nil:
+		return unknownFields(node.Alias, t, path, pos, fallbackFile)
+
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if isMergeKey(key) {
+				// Merged mappings must only hold fields of this struct too
+				for _, merged := range mergeSources(value) {
+					issues = append(issues, unknownFields(merged, t, path, pos, fallbackFile)...)
+				}
+				continue
+			}
+			child := joinPath(path, key.Value)
+
+			fieldType, known := fields[key.Value]
+			if !known {
+				issues = append(issues, Issue{
+					Path:     child,
+					Position: pos.lookup(child, fallbackFile),
+					Message:  unknownFieldMessage(key.Value, fields),
+				})
+				continue
+			}
+			issues = append(issues, unknownFields(value, fieldType, child, pos, fallbackFile)...)
+		}
+
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if isMergeKey(key) {
+				for _, merged := range mergeSources(value) {
+					issues = append(issues, unknownFields(merged, t, path, pos, fallbackFile)...)
+				}
+				continue
+			}
+			child := joinPath(path, key.Value)
+			issues = append(issues, unknownFields(value, t.Elem(), child, pos, fallbackFile)...)
+		}
+
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			issues = append(issues, unknownFields(item, t.Elem(), child, pos, fallbackFile)...)
+		}
+	}
+
+	return issues
+}
+
+// isMergeKey reports whether key is a YAML merge key (<<)
+func isMergeKey(key *yaml.Node) bool {
+	return key.Kind == yaml.ScalarNode && key.Tag == "!!merge"
+}
+
+// mergeSources returns the mappings a merge key merges in: a single alias or
+// mapping, or a sequence of them
+func mergeSources(value *yaml.Node) []*yaml.Node {
+	if value.Kind == yaml.SequenceNode {
+		return value.Content
+	}
+	return []*yaml.Node{value}
+}
+
+// yamlFields maps the YAML keys of a struct to their field types, flattening
+// inline structs the same way the YAML decoder does
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			for key, fieldType := range yamlFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// unknownFieldMessage names the unknown key and suggests a close match
+func unknownFieldMessage(key string, fields map[string]reflect.Type) string {
+	message := fmt.Sprintf("unknown field %q", key)
+
+	known := make([]string, 0, len(fields))
+	for name := range fields {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+
+	normalizedKey := normalizeKey(key)
+	for _, name := range known {
+		if normalizeKey(name) == normalizedKey || editDistance(name, key) <= 2 {
+			return message + fmt.Sprintf(", did you mean %q?", name)
+		}
+	}
+	return message
+}
+
+// normalizeKey lowercases a key and strips separators so that rateLimit,
+// ratelimit and rate-limit all compare equal to rate_limit
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
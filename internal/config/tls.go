@@ -0,0 +1,169 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultTLSVersion is used when min_version is not set
+const DefaultTLSVersion = "1.2"
+
+// tlsVersions maps min_version values to crypto/tls versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSVersion returns the crypto/tls version for a min_version value,
+// falling back to DefaultTLSVersion
+func TLSVersion(name string) uint16 {
+	if version, exists := tlsVersions[name]; exists {
+		return version
+	}
+	return tlsVersions[DefaultTLSVersion]
+}
+
+// CipherSuite returns the ID of a secure cipher suite by its IANA name, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+func CipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// Load reads and parses the certificate and key files
+func (c CertificateConfig) Load() (tls.Certificate, error) {
+	certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load certificate %s: %w", c.CertFile, err)
+	}
+	return certificate, nil
+}
+
+// ClientTLSConfig builds the TLS configuration used to connect to the
+// upstream. Without a CA file the system roots are trusted.
+func (u *UpstreamTLSConfig) ClientTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         TLSVersion(u.MinVersion),
+		ServerName:         u.ServerName,
+		InsecureSkipVerify: u.InsecureSkipVerify,
+	}
+
+	if u.CAFile != "" {
+		pool, err := LoadCertPool(u.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if u.CertFile != "" {
+		certificate, err := CertificateConfig{CertFile: u.CertFile, KeyFile: u.KeyFile}.Load()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", file)
+	}
+	return pool, nil
+}
+
+// CompilePattern compiles a certificate identity glob. Unlike path.Match,
+// '*' matches any run of characters including '/', so
+// 'spiffe://example.com/*' covers every path under the trust domain. '?'
+// matches one character, '[...]' a character class ('^' negates) and '\'
+// escapes the next character.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			expr.WriteString("(?s:.*)")
+		case '?':
+			expr.WriteString("(?s:.)")
+		case '\\':
+			if i++; i == len(pattern) {
+				return nil, fmt.Errorf("trailing escape in pattern %q", pattern)
+			}
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end, class, err := compileClass(pattern, i)
+			if err != nil {
+				return nil, err
+			}
+			expr.WriteString(class)
+			i = end
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// compileClass translates the character class starting at pattern[start]
+// and returns the index of its closing bracket
+func compileClass(pattern string, start int) (int, string, error) {
+	var class strings.Builder
+	class.WriteString("[")
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		class.WriteString("^")
+		i++
+	}
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			class.WriteString("]")
+			return i, class.String(), nil
+		}
+
+		escaped := pattern[i] == '\\' && i+1 < len(pattern)
+		if escaped {
+			i++
+		}
+		switch {
+		case pattern[i] == '-' && escaped:
+			class.WriteString(`\-`)
+		case pattern[i] == '-' && !first:
+			// A range between the neighbouring characters
+			class.WriteString("-")
+		default:
+			class.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+		i++
+	}
+	return 0, "", fmt.Errorf("unterminated character class in pattern %q", pattern)
+}
+
+// checkPatterns reports patterns that CompilePattern rejects
+func checkPatterns(listPath string, patterns []string, add func(path, message string, warning bool)) {
+	for i, pattern := range patterns {
+		if _, err := CompilePattern(pattern); err != nil {
+			add(fmt.Sprintf("%s[%d]", listPath, i), fmt.Sprintf("invalid pattern %q", pattern), false)
+		}
+	}
+}
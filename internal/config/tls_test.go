@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"spiffe://example.com/*", "spiffe://example.com/ns/prod/sa/web", true},
+		{"spiffe://example.com/*", "spiffe://example.com/", true},
+		{"spiffe://example.com/*", "spiffe://example.org/ns/prod", false},
+		{"spiffe://example.com/ns/*/sa/web", "spiffe://example.com/ns/prod/sa/web", true},
+		{"svc-*", "svc-orders", true},
+		{"svc-*", "web-orders", false},
+		{"svc-?", "svc-a", true},
+		{"svc-?", "svc-ab", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "api.example.com.evil.org", false},
+		{"node[0-9]", "node7", true},
+		{"node[^0-9]", "node7", false},
+		{"node[a\\-]", "node-", true},
+		{"a.b", "axb", false},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"[]]", "]", true},
+	}
+
+	for _, tt := range tests {
+		matcher, err := CompilePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("CompilePattern(%q) error = %v", tt.pattern, err)
+		}
+		if got := matcher.MatchString(tt.value); got != tt.want {
+			t.Errorf("CompilePattern(%q) matches %q = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompilePatternRejectsInvalid(t *testing.T) {
+	for _, pattern := range []string{"node[0-9", "trailing\\", "[z-a]", "[]"} {
+		if _, err := CompilePattern(pattern); err == nil {
+			t.Errorf("CompilePattern(%q) error = nil, want an error", pattern)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotPersistable is returned when runtime changes cannot be written back
+// to the config file without losing information
+var ErrNotPersistable = errors.New("config cannot be persisted")
+
+var (
+	// updateMu serializes config swaps from reloads and runtime updates so
+	// that read-modify-write cycles do not lose changes
+	updateMu sync.Mutex
+)
+
+// Update applies change to a copy of the active configuration, validates the
+// result with the same rules as LoadConfig and activates it atomically. The
+// services map is cloned before change runs; nested values must be replaced
+// rather than modified in place. When persist is true the new configuration
+// is also written back to the config file.
+func Update(change func(config *MainConfig) error, persist bool) (*Snapshot, error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	active := Current().Config()
+	config := *active
+	config.Services = maps.Clone(active.Services)
+	if config.Services == nil {
+		config.Services = make(map[string]ServiceConfig)
+	}
+
+	if err := change(&config); err != nil {
+		return nil, err
+	}
+
+	problems, warnings := validateConfig(&config, positions{}, "")
+	if len(problems) > 0 {
+		return nil, &ValidationError{Issues: append(problems, warnings...)}
+	}
+	logWarnings(warnings)
+	assignServiceNames(&config)
+
+	if persist {
+		if err := persistConfig(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	snapshot := newSnapshot(&config)
+	activate(snapshot)
+
+	log.Printf("Config updated at runtime (version %d, persisted: %t)", snapshot.Version(), persist)
+	return snapshot, nil
+}
+
+// persistConfig writes config to the base config file. Only single-file
+// configurations without placeholders can be persisted, otherwise overlays,
+// includes and secrets would be flattened into the base file.
+func persistConfig(config *MainConfig) error {
+	files := configFiles(configPath)
+	if _, err := os.Stat(files[1]); err == nil {
+		return fmt.Errorf("%w: overlay %s is in use", ErrNotPersistable, files[1])
+	}
+	if includes, err := includeFiles(configPath, config.Include); err != nil || len(includes) > 0 {
+		return fmt.Errorf("%w: included files are in use", ErrNotPersistable)
+	}
+
+	existing, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if containsPlaceholder(existing) {
+		return fmt.Errorf("%w: %s uses ${...} placeholders", ErrNotPersistable, configPath)
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), ".main-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), configPath); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
+
+// DecodeStrict decodes a YAML (or JSON) document into out, rejecting unknown
+// keys unless CONFIG_STRICT is false. Unknown keys are reported as a
+// *ValidationError.
+func DecodeStrict(data []byte, out any) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse document: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return fmt.Errorf("document is empty")
+	}
+
+	pos := make(positions)
+	pos.index(doc.Content[0], "", "")
+	problems, warnings := strictIssues(unknownFields(doc.Content[0], reflect.TypeOf(out), "", pos, ""))
+	if len(problems) > 0 {
+		return &ValidationError{Issues: problems}
+	}
+	logWarnings(warnings)
+
+	if err := doc.Content[0].Decode(out); err != nil {
+		return fmt.Errorf("failed to decode document: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// fileState captures the attributes used to detect a changed config file
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// statConfigFiles returns the state of every file the configuration is built
+// from. Missing optional files are reported with a zero state so that their
+// creation is picked up as a change.
+func statConfigFiles() map[string]fileState {
+	states := make(map[string]fileState)
+	for _, file := range sourceFiles(configPath) {
+		state := fileState{}
+		if info, err := os.Stat(file); err == nil {
+			state = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		states[file] = state
+	}
+	return states
+}
+
+// changed reports whether any file was added, removed or modified between two scans
+func changed(previous, next map[string]fileState) bool {
+	if len(previous) != len(next) {
+		return true
+	}
+	for file, state := range next {
+		last, exists := previous[file]
+		if !exists || !last.modTime.Equal(state.modTime) || last.size != state.size {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchConfig reloads the configuration whenever a config file changes on
+// disk or the process receives SIGHUP. It blocks until stop is closed.
+func WatchConfig(interval time.Duration, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := statConfigFiles()
+
+	reload := func(reason string) {
+		log.Printf("Reloading config (%s)", reason)
+		if err := ReloadConfig(); err != nil {
+			log.Printf("Config reload failed, keeping previous config: %v", err)
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			reload("SIGHUP")
+		case <-ticker.C:
+			states := statConfigFiles()
+			if changed(last, states) {
+				last = states
+				reload("file changed")
+			}
+		}
+	}
+}
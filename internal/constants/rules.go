@@ -1,6 +1,11 @@
 package constants
 
 import (
+	gatewayConfig "api-gateway/internal/config"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/kerimovok/go-pkg-utils/config"
 	"github.com/kerimovok/go-pkg-utils/validator"
 )
@@ -13,10 +18,115 @@ var EnvValidationRules = []validator.ValidationRule{
 		Rule:     config.IsValidPort,
 		Message:  "server port is required and must be a valid port number",
 	},
+	{
+		Variable: "SERVER_READ_TIMEOUT",
+		Default:  "30s",
+		Rule:     isPositiveDuration,
+		Message:  "SERVER_READ_TIMEOUT must be a positive duration",
+	},
+	{
+		Variable: "SERVER_WRITE_TIMEOUT",
+		Default:  "30s",
+		Rule:     isPositiveDuration,
+		Message:  "SERVER_WRITE_TIMEOUT must be a positive duration",
+	},
+	{
+		Variable: "SERVER_IDLE_TIMEOUT",
+		Default:  "120s",
+		Rule:     isPositiveDuration,
+		Message:  "SERVER_IDLE_TIMEOUT must be a positive duration",
+	},
+	{
+		Variable: "SERVER_BODY_LIMIT",
+		Default:  "4194304",
+		Rule: func(v string) bool {
+			n, err := strconv.Atoi(v)
+			return err == nil && n > 0
+		},
+		Message: "SERVER_BODY_LIMIT must be a positive number of bytes",
+	},
 	{
 		Variable: "GO_ENV",
 		Default:  "development",
 		Rule:     func(v string) bool { return v == "development" || v == "production" },
 		Message:  "GO_ENV must be either 'development' or 'production'",
 	},
+	{
+		Variable: "HTTP_REDIRECT_PORT",
+		Default:  "",
+		Rule:     func(v string) bool { return v == "" || config.IsValidPort(v) },
+		Message:  "HTTP_REDIRECT_PORT must be empty or a valid port number",
+	},
+	// PROXY protocol validation
+	{
+		Variable: "PROXY_PROTOCOL",
+		Default:  "false",
+		Rule:     func(v string) bool { return v == "true" || v == "false" },
+		Message:  "PROXY_PROTOCOL must be either 'true' or 'false'",
+	},
+	{
+		Variable: "PROXY_PROTOCOL_TRUSTED_CIDRS",
+		Default:  "",
+		Rule: func(v string) bool {
+			if config.GetEnv("PROXY_PROTOCOL") != "true" {
+				return true
+			}
+			return v != "" && isNetworkList(v)
+		},
+		Message: "PROXY_PROTOCOL_TRUSTED_CIDRS must list the IPs or CIDRs allowed to send PROXY protocol headers when PROXY_PROTOCOL is enabled",
+	},
+	{
+		Variable: "PROXY_PROTOCOL_HEADER_TIMEOUT",
+		Default:  "5s",
+		Rule:     isPositiveDuration,
+		Message:  "PROXY_PROTOCOL_HEADER_TIMEOUT must be a positive duration",
+	},
+	// Admin API validation
+	{
+		Variable: "ADMIN_PORT",
+		Default:  "",
+		Rule:     func(v string) bool { return v == "" || config.IsValidPort(v) },
+		Message:  "ADMIN_PORT must be empty or a valid port number",
+	},
+	{
+		Variable: "ADMIN_TOKEN",
+		Default:  "",
+		Rule:     func(v string) bool { return config.GetEnv("ADMIN_PORT") == "" || len(v) >= 16 },
+		Message:  "ADMIN_TOKEN must be at least 16 characters when ADMIN_PORT is set",
+	},
+	// Config validation
+	{
+		Variable: "CONFIG_PATH",
+		Default:  "config/main.yaml",
+		Rule:     func(v string) bool { return v != "" },
+		Message:  "CONFIG_PATH must point to the main config file",
+	},
+	{
+		Variable: "CONFIG_WATCH_INTERVAL",
+		Default:  "5s",
+		Rule:     isPositiveDuration,
+		Message:  "CONFIG_WATCH_INTERVAL must be a positive duration",
+	},
+	{
+		Variable: "CONFIG_STRICT",
+		Default:  "true",
+		Rule:     func(v string) bool { return v == "true" || v == "false" },
+		Message:  "CONFIG_STRICT must be either 'true' or 'false'",
+	},
+}
+
+// isPositiveDuration reports whether v parses as a duration greater than zero
+func isPositiveDuration(v string) bool {
+	d, err := time.ParseDuration(v)
+	return err == nil && d > 0
+}
+
+// isNetworkList reports whether v is a comma-separated list of IPs or CIDRs
+func isNetworkList(v string) bool {
+	for _, entry := range strings.Split(v, ",") {
+		if _, err := gatewayConfig.ParseNetwork(strings.TrimSpace(entry)); err != nil {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/breaker"
+	"api-gateway/internal/config"
+	"api-gateway/internal/health"
+	"api-gateway/internal/utils"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kerimovok/go-pkg-utils/httpx"
+	"gopkg.in/yaml.v3"
+)
+
+// errServiceNotFound is returned from config updates targeting a missing service
+var errServiceNotFound = errors.New("service not found")
+
+// maxBreakerEvents bounds the circuit breaker history kept for the admin API
+const maxBreakerEvents = 100
+
+var (
+	// breakerEvents holds the latest circuit breaker state changes, oldest first
+	breakerEvents   []breaker.Event
+	breakerEventsMu sync.Mutex
+)
+
+// AdminGetConfigHandler returns the full active configuration
+func AdminGetConfigHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		snapshot := config.Current()
+
+		document, err := toDocument(snapshot.Config())
+		if err != nil {
+			response := httpx.InternalServerError("Failed to encode config", err)
+			return httpx.SendResponse(c, response)
+		}
+
+		response := httpx.OK("Config retrieved", fiber.Map{
+			"version":   snapshot.Version(),
+			"loaded_at": snapshot.LoadedAt(),
+			"config":    document,
+		})
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminListServicesHandler lists the configured services and their upstreams
+func AdminListServicesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg := config.Current().Config()
+
+		names := make([]string, 0, len(cfg.Services))
+		for name := range cfg.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		services := make([]fiber.Map, 0, len(names))
+		for _, name := range names {
+			service := cfg.Services[name]
+			targets := make([]string, 0, len(service.Targets))
+			for _, target := range service.UpstreamTargets() {
+				targets = append(targets, target.URL)
+			}
+			services = append(services, fiber.Map{"name": name, "targets": targets})
+		}
+
+		response := httpx.OK("Services retrieved", services)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminGetServiceHandler returns the effective configuration of a service
+// with global defaults applied
+func AdminGetServiceHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg := config.Current().Config()
+
+		serviceConfig, err := utils.GetServiceConfig(c.Params("name"), cfg)
+		if err != nil {
+			response := httpx.NotFound("Service not found")
+			return httpx.SendResponse(c, response)
+		}
+
+		document, err := toDocument(serviceConfig)
+		if err != nil {
+			response := httpx.InternalServerError("Failed to encode service", err)
+			return httpx.SendResponse(c, response)
+		}
+
+		response := httpx.OK("Service retrieved", document)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminPutServiceHandler creates or replaces a service
+func AdminPutServiceHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+
+		var service config.ServiceConfig
+		if err := config.DecodeStrict(c.Body(), &service); err != nil {
+			return sendConfigError(c, err)
+		}
+
+		created := false
+		_, err := config.Update(func(cfg *config.MainConfig) error {
+			_, exists := cfg.Services[name]
+			created = !exists
+			cfg.Services[name] = service
+			return nil
+		}, c.QueryBool("persist"))
+		if err != nil {
+			return sendConfigError(c, err)
+		}
+
+		if created {
+			response := httpx.Created("Service created", fiber.Map{"name": name})
+			return httpx.SendResponse(c, response)
+		}
+		response := httpx.OK("Service updated", fiber.Map{"name": name})
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminDeleteServiceHandler removes a service
+func AdminDeleteServiceHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+
+		_, err := config.Update(func(cfg *config.MainConfig) error {
+			if _, exists := cfg.Services[name]; !exists {
+				return errServiceNotFound
+			}
+			delete(cfg.Services, name)
+			return nil
+		}, c.QueryBool("persist"))
+		if err != nil {
+			return sendConfigError(c, err)
+		}
+
+		response := httpx.OK("Service deleted", fiber.Map{"name": name})
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminGetGlobalHandler returns the global settings
+func AdminGetGlobalHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		document, err := toDocument(config.Current().Config().Global)
+		if err != nil {
+			response := httpx.InternalServerError("Failed to encode global settings", err)
+			return httpx.SendResponse(c, response)
+		}
+
+		response := httpx.OK("Global settings retrieved", document)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminPutGlobalHandler replaces the global settings
+func AdminPutGlobalHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var global config.GlobalConfig
+		if err := config.DecodeStrict(c.Body(), &global); err != nil {
+			return sendConfigError(c, err)
+		}
+
+		_, err := config.Update(func(cfg *config.MainConfig) error {
+			cfg.Global = &global
+			return nil
+		}, c.QueryBool("persist"))
+		if err != nil {
+			return sendConfigError(c, err)
+		}
+
+		response := httpx.OK("Global settings updated", nil)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminUpstreamsHandler reports the state of every upstream target,
+// including active health check results where enabled
+func AdminUpstreamsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg := config.Current().Config()
+
+		checked := make(map[string]health.TargetStatus)
+		for _, status := range health.Statuses() {
+			checked[status.Service+"|"+status.URL] = status
+		}
+
+		names := make([]string, 0, len(cfg.Services))
+		for name := range cfg.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		upstreams := make([]fiber.Map, 0, len(names))
+		for _, name := range names {
+			service := cfg.Services[name]
+			for _, target := range service.UpstreamTargets() {
+				state := balancer.StateFor(name, target.URL)
+				upstream := fiber.Map{
+					"service":   name,
+					"url":       target.URL,
+					"available": state.Available(),
+					"healthy":   state.Healthy(),
+					"ejected":   state.Ejected(),
+					"active":    state.Active(),
+				}
+				if until := state.EjectedUntil(); !until.IsZero() {
+					upstream["ejected_until"] = until
+				}
+				if status, exists := checked[name+"|"+target.URL]; exists {
+					upstream["health_check"] = status
+				}
+				upstreams = append(upstreams, upstream)
+			}
+		}
+
+		response := httpx.OK("Upstreams retrieved", upstreams)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// AdminCircuitBreakersHandler reports the circuit breaker state of every
+// service that has one enabled
+func AdminCircuitBreakersHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg := config.Current().Config()
+
+		statuses := make([]breaker.Status, 0)
+		for _, status := range breaker.Statuses() {
+			service, exists := cfg.Services[status.Service]
+			if !exists || service.CircuitBreaker == nil || service.CircuitBreaker.Enabled == nil || !*service.CircuitBreaker.Enabled {
+				continue
+			}
+			statuses = append(statuses, status)
+		}
+
+		response := httpx.OK("Circuit breakers retrieved", statuses)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// RecordBreakerEvent keeps a circuit breaker state change for the admin API.
+// Register it with breaker.OnStateChange.
+func RecordBreakerEvent(event breaker.Event) {
+	breakerEventsMu.Lock()
+	defer breakerEventsMu.Unlock()
+
+	breakerEvents = append(breakerEvents, event)
+	if len(breakerEvents) > maxBreakerEvents {
+		breakerEvents = append([]breaker.Event(nil), breakerEvents[len(breakerEvents)-maxBreakerEvents:]...)
+	}
+}
+
+// AdminCircuitBreakerEventsHandler lists the latest circuit breaker state
+// changes, newest first
+func AdminCircuitBreakerEventsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		breakerEventsMu.Lock()
+		events := make([]breaker.Event, len(breakerEvents))
+		for i, event := range breakerEvents {
+			events[len(events)-1-i] = event
+		}
+		breakerEventsMu.Unlock()
+
+		response := httpx.OK("Circuit breaker events retrieved", events)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// sendConfigError maps config update errors to responses
+func sendConfigError(c *fiber.Ctx, err error) error {
+	var validationErr *config.ValidationError
+	switch {
+	case errors.Is(err, errServiceNotFound):
+		response := httpx.NotFound("Service not found")
+		return httpx.SendResponse(c, response)
+	case errors.Is(err, config.ErrNotPersistable):
+		response := httpx.Conflict("Failed to persist configuration", err)
+		return httpx.SendResponse(c, response)
+	case errors.As(err, &validationErr):
+		fields := make([]httpx.ValidationError, 0, len(validationErr.Issues))
+		for _, issue := range validationErr.Issues {
+			message := issue.Message
+			if issue.Warning {
+				message = "warning: " + message
+			}
+			fields = append(fields, httpx.ValidationError{Field: issue.Path, Message: message})
+		}
+		response := httpx.UnprocessableEntityWithValidation("Invalid configuration", fields)
+		return httpx.SendValidationResponse(c, response)
+	default:
+		response := httpx.BadRequest("Failed to apply configuration", err)
+		return httpx.SendResponse(c, response)
+	}
+}
+
+// redactedValue replaces secrets in admin responses
+const redactedValue = "[REDACTED]"
+
+// secretFields lists the settings holding secrets, by parent and field name
+var secretFields = map[string]map[string]bool{
+	"auth": {"value": true},
+}
+
+// toDocument converts config structs into generic maps keyed by their YAML
+// names so JSON responses use the same keys as the config file. Secrets are
+// redacted.
+func toDocument(value any) (any, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+	redact(document, "")
+	return document, nil
+}
+
+// redact replaces the secret fields below document in place
+func redact(document any, key string) {
+	switch node := document.(type) {
+	case map[string]any:
+		for child, value := range node {
+			if secretFields[key][child] {
+				node[child] = redactedValue
+				continue
+			}
+			redact(value, child)
+		}
+	case []any:
+		for _, item := range node {
+			redact(item, key)
+		}
+	}
+}
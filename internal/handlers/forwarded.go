@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"api-gateway/internal/pipeline"
+	"api-gateway/internal/utils"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// clientForwardedHeaders are the proxy headers a client could send to spoof
+// its address, scheme or host
+var clientForwardedHeaders = []string{
+	fiber.HeaderXForwardedFor,
+	fiber.HeaderXForwardedProto,
+	fiber.HeaderXForwardedHost,
+	"X-Forwarded-Prefix",
+	fiber.HeaderForwarded,
+}
+
+// setForwardedHeaders adds the selected proxy headers to the request before
+// it is sent upstream. They are set once, so retries send the same values.
+// Headers from a trusted proxy are extended or passed on; headers from any
+// other peer are dropped so only what the gateway observed reaches upstream.
+func setForwardedHeaders(c *fiber.Ctx, headers pipeline.ForwardedHeaders) {
+	header := &c.Request().Header
+
+	peer := c.Context().RemoteIP().String()
+	proto := "http"
+	if c.Context().IsTLS() {
+		proto = "https"
+	}
+	host := string(c.Request().Host())
+
+	if pipeline.FromCtx(c).TrustedPeer(c) {
+		if forwarded := c.Get(fiber.HeaderXForwardedProto); forwarded != "" {
+			proto = forwarded
+		}
+		if forwarded := c.Get(fiber.HeaderXForwardedHost); forwarded != "" {
+			host = forwarded
+		}
+	} else {
+		for _, key := range clientForwardedHeaders {
+			header.Del(key)
+		}
+	}
+
+	if headers.For {
+		appendHeader(header, fiber.HeaderXForwardedFor, peer)
+	}
+	if headers.Proto {
+		header.Set(fiber.HeaderXForwardedProto, proto)
+	}
+	if headers.Host {
+		header.Set(fiber.HeaderXForwardedHost, host)
+	}
+	if headers.Prefix {
+		prefix, _ := splitPath(c)
+		header.Set("X-Forwarded-Prefix", prefix)
+	}
+	if headers.Forwarded {
+		appendHeader(header, fiber.HeaderForwarded, forwardedElement(peer, proto, host))
+	}
+	if headers.RequestID {
+		if requestID, ok := c.Locals(utils.RequestIDLocalsKey).(string); ok && requestID != "" {
+			header.Set(fiber.HeaderXRequestID, requestID)
+		}
+	}
+}
+
+// appendHeader adds value to a comma-separated header, keeping earlier entries
+func appendHeader(header *fasthttp.RequestHeader, key, value string) {
+	if existing := header.Peek(key); len(existing) > 0 {
+		value = string(existing) + ", " + value
+	}
+	header.Set(key, value)
+}
+
+// forwardedElement renders one RFC 7239 Forwarded element. IPv6 addresses
+// are bracketed and quoted, and the host is quoted when it has a port.
+func forwardedElement(peer, proto, host string) string {
+	node := peer
+	if ip := net.ParseIP(peer); ip != nil && ip.To4() == nil {
+		node = `"[` + peer + `]"`
+	}
+
+	var b strings.Builder
+	b.WriteString("for=" + node)
+	if host != "" {
+		b.WriteString(";host=" + quoteForwarded(host))
+	}
+	b.WriteString(";proto=" + proto)
+	return b.String()
+}
+
+// quoteForwarded quotes a Forwarded parameter value unless it is a plain token
+func quoteForwarded(value string) string {
+	if strings.ContainsAny(value, ":[]\\\" ") {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `"`, `\"`) + `"`
+	}
+	return value
+}
@@ -1,33 +1,171 @@
 package handlers
 
 import (
-	"api-gateway/internal/config"
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/pipeline"
+	"api-gateway/internal/upstream"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/proxy"
+	pkgConfig "github.com/kerimovok/go-pkg-utils/config"
 	"github.com/kerimovok/go-pkg-utils/httpx"
 )
 
+// Error codes for requests the gateway refuses before reaching an upstream
+const (
+	CodeCircuitOpen = "CIRCUIT_OPEN"
+	CodeNoUpstream  = "NO_UPSTREAM_AVAILABLE"
+)
+
 // ProxyHandler forwards requests to the upstream service
 func ProxyHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		cfg := config.GetConfig()
-		serviceName := c.Params("service")
-
 		// Find the corresponding service configuration
-		service, exists := cfg.Services[serviceName]
+		service, exists := pipeline.ServiceFromCtx(c)
 		if !exists {
 			response := httpx.NotFound("Service not found")
 			return httpx.SendResponse(c, response)
 		}
 
-		// Forward the request to the upstream URL
-		targetURL := service.URL + "/" + c.Params("*")
-		if err := proxy.Forward(targetURL)(c); err != nil {
-			response := httpx.BadGateway("Failed to proxy request")
+		// Fail fast while the service's circuit breaker is open
+		done, retryAfter, allowed := service.Breaker.Allow()
+		if !allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			response := httpx.ServiceUnavailable("Circuit breaker is open")
+			response.Error = CodeCircuitOpen
 			return httpx.SendResponse(c, response)
 		}
 
+		setForwardedHeaders(c, service.ForwardedHeaders)
+		service.Retry.Begin()
+
+		// The total timeout covers every attempt and the backoff between them
+		var deadline time.Time
+		if service.Timeout > 0 {
+			deadline = time.Now().Add(service.Timeout)
+		}
+
+		var (
+			err    error
+			failed bool
+			target *balancer.Target
+			tried  []*balancer.Target
+		)
+		for attempt := 1; ; attempt++ {
+			// Prefer a target that has not been tried yet, falling back to
+			// any available one when every target has been tried
+			target = service.Upstream.Pick(c, tried...)
+			if target == nil && len(tried) > 0 {
+				target = service.Upstream.Pick(c)
+			}
+			if target == nil {
+				done(false)
+				response := httpx.ServiceUnavailable("No upstream available")
+				response.Error = CodeNoUpstream
+				return httpx.SendResponse(c, response)
+			}
+
+			err = forward(c, service, target, deadline)
+
+			// Feed connection errors, timeouts and 5xx responses to outlier
+			// detection; the circuit breaker only sees the final outcome
+			status := c.Response().StatusCode()
+			failed = err != nil || status >= fiber.StatusInternalServerError
+			service.Upstream.Report(target, failed)
+
+			if !service.Retry.ShouldRetry(c.Method(), attempt, err, status) {
+				break
+			}
+
+			// Give up when no time would be left after the backoff
+			backoff := service.Retry.Backoff(attempt)
+			if !deadline.IsZero() && time.Until(deadline) <= backoff {
+				break
+			}
+			tried = append(tried, target)
+			time.Sleep(backoff)
+		}
+		done(!failed)
+
+		if err != nil {
+			code := upstream.Classify(err)
+			log.Printf("Upstream %s request to %s failed: %s: %v", service.Name, target.URL, code, err)
+			return sendUpstreamError(c, code, err)
+		}
+
 		return nil
 	}
 }
+
+// forward sends the request to target with the service's client, bounded by
+// the request deadline unless it is zero, counting it as in flight meanwhile
+func forward(c *fiber.Ctx, service *pipeline.Service, target *balancer.Target, deadline time.Time) error {
+	target.Acquire()
+	defer target.Release()
+
+	targetURL := upstreamURL(c, target.URL)
+	if !deadline.IsZero() {
+		return proxy.DoDeadline(c, targetURL, deadline, service.Client)
+	}
+	return proxy.Do(c, targetURL, service.Client)
+}
+
+// sendUpstreamError answers a failed upstream request with its error code,
+// adding the underlying error only in development
+func sendUpstreamError(c *fiber.Ctx, code string, err error) error {
+	var response httpx.Response
+	switch code {
+	case upstream.CodeTimeout:
+		response = httpx.GatewayTimeout("Upstream timed out")
+	case upstream.CodeDNS:
+		response = httpx.BadGateway("Upstream host could not be resolved")
+	case upstream.CodeUnreachable:
+		response = httpx.BadGateway("Upstream is unreachable")
+	case upstream.CodeTLS:
+		response = httpx.BadGateway("Upstream TLS handshake failed")
+	case upstream.CodeConnectionReset:
+		response = httpx.BadGateway("Upstream closed the connection")
+	case upstream.CodeBadResponse:
+		response = httpx.BadGateway("Upstream sent an invalid response")
+	default:
+		response = httpx.BadGateway("Failed to proxy request")
+	}
+	response.Error = code
+
+	if pkgConfig.GetEnvOrDefault("GO_ENV", "development") == "development" {
+		response.Data = fiber.Map{"detail": err.Error()}
+	}
+	return httpx.SendResponse(c, response)
+}
+
+// upstreamURL joins the upstream base URL with the request path after the
+// /:service prefix and the query string, both exactly as the client sent
+// them so percent-encoding (including encoded slashes) and repeated query
+// keys reach the upstream unchanged
+func upstreamURL(c *fiber.Ctx, base string) string {
+	_, rest := splitPath(c)
+
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(base, "/"))
+	b.WriteString(rest)
+	if query := c.Request().URI().QueryString(); len(query) > 0 {
+		b.WriteByte('?')
+		b.Write(query)
+	}
+	return b.String()
+}
+
+// splitPath splits the raw request path /<service>[/<rest>] into the
+// /<service> prefix and the rest, which is at least "/"
+func splitPath(c *fiber.Ctx) (prefix, rest string) {
+	rawPath := string(c.Request().URI().PathOriginal())
+	if idx := strings.IndexByte(strings.TrimPrefix(rawPath, "/"), '/'); idx >= 0 {
+		return rawPath[:idx+1], rawPath[idx+1:]
+	}
+	return rawPath, "/"
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"api-gateway/internal/config"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestProxyHandlerForwardsRawPathAndQuery(t *testing.T) {
+	// The stub upstream echoes the request target exactly as received
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RequestURI)
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "main.yaml")
+	mainConfig := fmt.Sprintf(`
+services:
+    svc:
+        url: '%[1]s'
+    based:
+        url: '%[1]s/api/v1/'
+`, upstream.URL)
+	if err := os.WriteFile(path, []byte(mainConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.All("/:service/*", ProxyHandler())
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"plain path", "/svc/users/42", "/users/42"},
+		{"encoded slash", "/svc/files/a%2Fb", "/files/a%2Fb"},
+		{"unicode", "/svc/users/m%C3%BCller", "/users/m%C3%BCller"},
+		{"repeated query keys", "/svc/search?q=x&q=y", "/search?q=x&q=y"},
+		{"encoded query", "/svc/search?q=a%26b&tag=%C3%BC", "/search?q=a%26b&tag=%C3%BC"},
+		{"bare service", "/svc", "/"},
+		{"bare service with query", "/svc?q=x", "/?q=x"},
+		{"trailing slash", "/svc/", "/"},
+		{"base url with path", "/based/users?id=1", "/api/v1/users?id=1"},
+		{"base url with path, bare service", "/based", "/api/v1/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, tt.target, nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+			}
+			if got := string(body); got != tt.want {
+				t.Errorf("upstream received %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package health
+
+import (
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Defaults applied to unset health check settings
+const (
+	defaultPath               = "/health"
+	defaultExpectedStatus     = fasthttp.StatusOK
+	defaultInterval           = 10 * time.Second
+	defaultTimeout            = 2 * time.Second
+	defaultHealthyThreshold   = 2
+	defaultUnhealthyThreshold = 3
+)
+
+// TargetStatus reports the active health check state of one target
+type TargetStatus struct {
+	Service              string    `json:"service"`
+	URL                  string    `json:"url"`
+	Healthy              bool      `json:"healthy"`
+	ConsecutiveSuccesses int       `json:"consecutive_successes"`
+	ConsecutiveFailures  int       `json:"consecutive_failures"`
+	LastCheck            time.Time `json:"last_check"`
+	LastError            string    `json:"last_error,omitempty"`
+}
+
+// prober periodically checks one target and updates its balancer state
+type prober struct {
+	service  string
+	url      string
+	settings config.HealthCheckConfig
+	tls      config.UpstreamTLSConfig
+	state    *balancer.State
+	client   *fasthttp.Client
+	stop     chan struct{}
+
+	mu     sync.Mutex
+	status TargetStatus
+}
+
+var (
+	// probers holds the running probers keyed by service and target URL
+	probers   = make(map[string]*prober)
+	probersMu sync.Mutex
+)
+
+// Sync starts, restarts and stops probers so they match the health check
+// settings of snapshot. Targets without health checks are marked healthy.
+func Sync(snapshot *config.Snapshot) {
+	probersMu.Lock()
+	defer probersMu.Unlock()
+
+	wanted := make(map[string]bool)
+	for name, service := range snapshot.Config().Services {
+		settings, enabled := withDefaults(service.HealthCheck)
+		var upstreamTLS config.UpstreamTLSConfig
+		if service.UpstreamTLS != nil {
+			upstreamTLS = *service.UpstreamTLS
+		}
+
+		for _, target := range service.UpstreamTargets() {
+			key := name + "|" + target.URL
+			state := balancer.StateFor(name, target.URL)
+
+			if !enabled {
+				state.SetHealthy(true)
+				continue
+			}
+			wanted[key] = true
+
+			// Keep probers whose settings did not change
+			if existing, running := probers[key]; running {
+				if existing.settings == settings && existing.tls == upstreamTLS {
+					continue
+				}
+				close(existing.stop)
+			}
+
+			p := newProber(name, target.URL, settings, upstreamTLS, state)
+			probers[key] = p
+			go p.run()
+		}
+	}
+
+	for key, p := range probers {
+		if !wanted[key] {
+			close(p.stop)
+			p.state.SetHealthy(true)
+			delete(probers, key)
+		}
+	}
+}
+
+// Stop stops every running prober
+func Stop() {
+	probersMu.Lock()
+	defer probersMu.Unlock()
+
+	for key, p := range probers {
+		close(p.stop)
+		delete(probers, key)
+	}
+}
+
+// Statuses returns the state of every actively checked target
+func Statuses() []TargetStatus {
+	probersMu.Lock()
+	statuses := make([]TargetStatus, 0, len(probers))
+	for _, p := range probers {
+		p.mu.Lock()
+		statuses = append(statuses, p.status)
+		p.mu.Unlock()
+	}
+	probersMu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Service != statuses[j].Service {
+			return statuses[i].Service < statuses[j].Service
+		}
+		return statuses[i].URL < statuses[j].URL
+	})
+	return statuses
+}
+
+// withDefaults fills unset settings and reports whether checks are enabled
+func withDefaults(healthCheck *config.HealthCheckConfig) (config.HealthCheckConfig, bool) {
+	if healthCheck == nil || healthCheck.Enabled == nil || !*healthCheck.Enabled {
+		return config.HealthCheckConfig{}, false
+	}
+
+	settings := *healthCheck
+	settings.Enabled = nil // compared by value when syncing
+	if settings.Path == "" {
+		settings.Path = defaultPath
+	}
+	if settings.ExpectedStatus == 0 {
+		settings.ExpectedStatus = defaultExpectedStatus
+	}
+	if settings.Interval == 0 {
+		settings.Interval = defaultInterval
+	}
+	if settings.Timeout == 0 {
+		settings.Timeout = defaultTimeout
+	}
+	if settings.HealthyThreshold == 0 {
+		settings.HealthyThreshold = defaultHealthyThreshold
+	}
+	if settings.UnhealthyThreshold == 0 {
+		settings.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	return settings, true
+}
+
+// newProber builds a prober that connects to https targets with the
+// service's upstream TLS settings
+func newProber(service, url string, settings config.HealthCheckConfig, upstreamTLS config.UpstreamTLSConfig, state *balancer.State) *prober {
+	client := &fasthttp.Client{NoDefaultUserAgentHeader: true}
+	if upstreamTLS != (config.UpstreamTLSConfig{}) {
+		tlsConfig, err := upstreamTLS.ClientTLSConfig()
+		if err != nil {
+			// Fail the probes instead of trusting the system roots
+			log.Printf("Health checks for %s reject all upstream certificates: %v", service, err)
+			tlsConfig = &tls.Config{RootCAs: x509.NewCertPool()}
+		}
+		client.TLSConfig = tlsConfig
+	}
+
+	return &prober{
+		service:  service,
+		url:      url,
+		settings: settings,
+		tls:      upstreamTLS,
+		state:    state,
+		client:   client,
+		stop:     make(chan struct{}),
+		status: TargetStatus{
+			Service: service,
+			URL:     url,
+			Healthy: state.Healthy(),
+		},
+	}
+}
+
+// run probes the target until the prober is stopped
+func (p *prober) run() {
+	ticker := time.NewTicker(p.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.record(p.probe())
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe performs a single health check request
+func (p *prober) probe() error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(strings.TrimSuffix(p.url, "/") + "/" + strings.TrimPrefix(p.settings.Path, "/"))
+	req.Header.SetMethod(fasthttp.MethodGet)
+	req.Header.SetUserAgent("api-gateway-health-check")
+
+	if err := p.client.DoTimeout(req, resp, p.settings.Timeout); err != nil {
+		return err
+	}
+	if status := resp.StatusCode(); status != p.settings.ExpectedStatus {
+		return fmt.Errorf("unexpected status %d, expected %d", status, p.settings.ExpectedStatus)
+	}
+	return nil
+}
+
+// record applies a probe result and flips the target state once a threshold is reached
+func (p *prober) record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A replaced prober must not override the state of its successor
+	select {
+	case <-p.stop:
+		return
+	default:
+	}
+
+	p.status.LastCheck = time.Now()
+	if err != nil {
+		p.status.LastError = err.Error()
+		p.status.ConsecutiveFailures++
+		p.status.ConsecutiveSuccesses = 0
+		if p.status.Healthy && p.status.ConsecutiveFailures >= p.settings.UnhealthyThreshold {
+			p.status.Healthy = false
+			p.state.SetHealthy(false)
+			log.Printf("Upstream %s target %s is unhealthy: %v", p.service, p.url, err)
+		}
+		return
+	}
+
+	p.status.LastError = ""
+	p.status.ConsecutiveSuccesses++
+	p.status.ConsecutiveFailures = 0
+	if !p.status.Healthy && p.status.ConsecutiveSuccesses >= p.settings.HealthyThreshold {
+		p.status.Healthy = true
+		p.state.SetHealthy(true)
+		log.Printf("Upstream %s target %s is healthy again", p.service, p.url)
+	}
+}
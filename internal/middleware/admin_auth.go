@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kerimovok/go-pkg-utils/httpx"
+)
+
+// AdminAuthMiddleware protects the admin API with a static bearer token
+func AdminAuthMiddleware(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provided, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !found || provided == "" {
+			response := httpx.Unauthorized("Admin token is missing")
+			return httpx.SendResponse(c, response)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			response := httpx.Forbidden("Invalid admin token")
+			return httpx.SendResponse(c, response)
+		}
+
+		return c.Next()
+	}
+}
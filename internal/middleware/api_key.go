@@ -1,8 +1,7 @@
 package middleware
 
 import (
-	"api-gateway/internal/config"
-	internalUtils "api-gateway/internal/utils"
+	"api-gateway/internal/pipeline"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,14 +11,12 @@ import (
 // APIKeyMiddleware validates the API key for a service
 func APIKeyMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		cfg := config.GetConfig()
-		serviceName := c.Params("service")
-
-		serviceConfig, err := internalUtils.GetServiceConfig(serviceName, &cfg)
-		if err != nil {
+		service, exists := pipeline.ServiceFromCtx(c)
+		if !exists {
 			response := httpx.NotFound("Service not found")
 			return httpx.SendResponse(c, response)
 		}
+		serviceConfig := service.Config
 
 		// Skip if auth config is nil or not enabled
 		if serviceConfig.Auth == nil || serviceConfig.Auth.Enabled == nil || !*serviceConfig.Auth.Enabled {
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"api-gateway/internal/pipeline"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kerimovok/go-pkg-utils/httpx"
+)
+
+// ClientCertMiddleware authenticates callers by TLS client certificate for
+// services with mTLS enabled and forwards the verified identity upstream
+func ClientCertMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		service, exists := pipeline.ServiceFromCtx(c)
+		if !exists {
+			response := httpx.NotFound("Service not found")
+			return httpx.SendResponse(c, response)
+		}
+
+		clientAuth := service.ClientAuth
+		if clientAuth == nil {
+			return c.Next()
+		}
+
+		// Identity headers may only come from the gateway itself
+		header := &c.Request().Header
+		header.Del(clientAuth.SubjectHeader)
+		header.Del(clientAuth.SANHeader)
+		header.Del(clientAuth.FingerprintHeader)
+
+		var certificates []*x509.Certificate
+		if state := c.Context().TLSConnectionState(); state != nil {
+			certificates = state.PeerCertificates
+		}
+
+		if len(certificates) == 0 {
+			if clientAuth.Required {
+				response := httpx.Unauthorized("Client certificate is required")
+				return httpx.SendResponse(c, response)
+			}
+			return c.Next()
+		}
+
+		leaf := certificates[0]
+		if !verifyClientCert(clientAuth, certificates) {
+			response := httpx.Forbidden("Client certificate is not trusted")
+			return httpx.SendResponse(c, response)
+		}
+
+		sans := certificateSANs(leaf)
+		if !matchesAny(clientAuth.AllowedSubjects, []string{leaf.Subject.CommonName}) ||
+			!matchesAny(clientAuth.AllowedSANs, sans) {
+			response := httpx.Forbidden("Client certificate is not allowed")
+			return httpx.SendResponse(c, response)
+		}
+
+		fingerprint := sha256.Sum256(leaf.Raw)
+		header.Set(clientAuth.SubjectHeader, leaf.Subject.String())
+		header.Set(clientAuth.SANHeader, strings.Join(sans, ","))
+		header.Set(clientAuth.FingerprintHeader, hex.EncodeToString(fingerprint[:]))
+
+		return c.Next()
+	}
+}
+
+// verifyClientCert checks the presented chain against the service's CA bundle
+func verifyClientCert(clientAuth *pipeline.ClientAuth, certificates []*x509.Certificate) bool {
+	if clientAuth.Roots == nil {
+		return false
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err := certificates[0].Verify(x509.VerifyOptions{
+		Roots:         clientAuth.Roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err == nil
+}
+
+// certificateSANs lists the DNS, email, IP and URI subject alternative names
+func certificateSANs(certificate *x509.Certificate) []string {
+	sans := append([]string{}, certificate.DNSNames...)
+	sans = append(sans, certificate.EmailAddresses...)
+	for _, ip := range certificate.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range certificate.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// matchesAny reports whether any value matches any of the compiled
+// patterns. An empty pattern list allows everything.
+func matchesAny(patterns []*regexp.Regexp, values []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if pattern.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
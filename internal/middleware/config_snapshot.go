@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"api-gateway/internal/pipeline"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConfigVersionHeader reports which configuration snapshot served a request
+const ConfigVersionHeader = "X-Config-Version"
+
+// ConfigSnapshotMiddleware pins the current configuration snapshot and its
+// compiled pipeline to the request and exposes the version in the response
+// headers
+func ConfigSnapshotMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		snapshot := pipeline.Pin(c).Snapshot()
+		err := c.Next()
+
+		// Set afterwards since proxying replaces the whole response
+		c.Set(ConfigVersionHeader, strconv.FormatUint(snapshot.Version(), 10))
+		return err
+	}
+}
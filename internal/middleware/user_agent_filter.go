@@ -1,8 +1,7 @@
 package middleware
 
 import (
-	"api-gateway/internal/config"
-	internalUtils "api-gateway/internal/utils"
+	"api-gateway/internal/pipeline"
 	"strings"
 	"sync"
 	"time"
@@ -60,15 +59,8 @@ func cleanupCacheIfNeeded() {
 
 func UserAgentFilter() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		cfg := config.GetConfig()
-		serviceName := c.Params("service")
-		serviceConfig, err := internalUtils.GetServiceConfig(serviceName, &cfg)
-		if err != nil {
-			response := httpx.NotFound("Service not found")
-			return httpx.SendResponse(c, response)
-		}
-
-		if serviceConfig == nil {
+		service, exists := pipeline.ServiceFromCtx(c)
+		if !exists {
 			response := httpx.NotFound("Service not found")
 			return httpx.SendResponse(c, response)
 		}
@@ -81,63 +73,45 @@ func UserAgentFilter() fiber.Handler {
 
 		normalizedUA := getNormalizedUserAgent(userAgent)
 
-		// Check service-specific blocklist first (more specific rules take precedence)
-		if len(serviceConfig.UserAgentBlocklist) > 0 && isUserAgentBlocked(normalizedUA, serviceConfig.UserAgentBlocklist) {
+		// Global entries are already merged in according to the service's inherit mode
+		if len(service.UserAgentBlocklist) > 0 && isUserAgentBlocked(normalizedUA, service.UserAgentBlocklist) {
 			response := httpx.Forbidden("User-Agent is blocked for this service")
 			return httpx.SendResponse(c, response)
 		}
 
-		// Then check global blocklist
-		if cfg.Global != nil && len(cfg.Global.UserAgentBlocklist) > 0 && isUserAgentBlocked(normalizedUA, cfg.Global.UserAgentBlocklist) {
-			response := httpx.Forbidden("User-Agent is blocked globally")
-			return httpx.SendResponse(c, response)
-		}
-
-		// Check service-specific allowlist if defined
-		if len(serviceConfig.UserAgentAllowlist) > 0 {
-			if !isUserAgentAllowed(normalizedUA, serviceConfig.UserAgentAllowlist) {
+		// Check allowlist if defined
+		if len(service.UserAgentAllowlist) > 0 {
+			if !isUserAgentAllowed(normalizedUA, service.UserAgentAllowlist) {
 				response := httpx.Forbidden("User-Agent is not allowed for this service")
 				return httpx.SendResponse(c, response)
 			}
-			// If allowed by service rules, proceed to next middleware
-			return c.Next()
-		}
-
-		// Check global allowlist if defined
-		if cfg.Global != nil && len(cfg.Global.UserAgentAllowlist) > 0 {
-			if !isUserAgentAllowed(normalizedUA, cfg.Global.UserAgentAllowlist) {
-				response := httpx.Forbidden("User-Agent is not allowed globally")
-				return httpx.SendResponse(c, response)
-			}
 		}
 
-		// If no allowlists are defined, allow the request to proceed
+		// If no allowlist is defined, allow the request to proceed
 		return c.Next()
 	}
 }
 
+// isUserAgentBlocked expects the blocklist patterns to be lowercased already
 func isUserAgentBlocked(normalizedUA string, blocklist []string) bool {
 	if blocklist == nil {
 		return false
 	}
 	for _, blocked := range blocklist {
-		// Convert blocklist item to lowercase once
-		normalizedBlocked := strings.ToLower(blocked)
-		if strings.Contains(normalizedUA, normalizedBlocked) {
+		if strings.Contains(normalizedUA, blocked) {
 			return true
 		}
 	}
 	return false
 }
 
+// isUserAgentAllowed expects the allowlist patterns to be lowercased already
 func isUserAgentAllowed(normalizedUA string, allowlist []string) bool {
 	if allowlist == nil {
 		return false
 	}
 	for _, allowed := range allowlist {
-		// Convert allowlist item to lowercase once
-		normalizedAllowed := strings.ToLower(allowed)
-		if strings.Contains(normalizedUA, normalizedAllowed) {
+		if strings.Contains(normalizedUA, allowed) {
 			return true
 		}
 	}
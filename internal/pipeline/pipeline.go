@@ -0,0 +1,403 @@
+package pipeline
+
+import (
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/breaker"
+	"api-gateway/internal/config"
+	"api-gateway/internal/retry"
+	"api-gateway/internal/utils"
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cache"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/gofiber/storage/memory"
+	"github.com/kerimovok/go-pkg-utils/httpx"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// LocalsKey is the fiber locals key holding the pipeline pinned to a request
+	LocalsKey = "pipeline"
+	// ServiceLocalsKey holds the compiled service addressed by the request
+	ServiceLocalsKey = "pipeline_service"
+)
+
+var (
+	// Stores are shared by every compiled pipeline so rate limit counters and
+	// cached responses survive config reloads
+	limiterStore = memory.New()
+	cacheStore   = memory.New(memory.Config{
+		GCInterval: 10 * time.Second,
+	})
+
+	// current is the pipeline compiled for the most recent snapshot
+	current   atomic.Pointer[Pipeline]
+	compileMu sync.Mutex
+)
+
+// Service is a service compiled into ready-to-run request handling state
+type Service struct {
+	Name string
+	// Config is the effective service configuration with global settings applied
+	Config *config.ServiceConfig
+
+	IPAllowList        []*net.IPNet
+	IPBlockList        []*net.IPNet
+	UserAgentAllowlist []string
+	UserAgentBlocklist []string
+
+	// RateLimiter and Cache are nil when the feature is disabled
+	RateLimiter fiber.Handler
+	Cache       fiber.Handler
+
+	// Upstream balances requests across the service's targets
+	Upstream *balancer.Pool
+	// Breaker is nil when the circuit breaker is disabled
+	Breaker *breaker.Breaker
+	// Retry is nil when retries are disabled
+	Retry *retry.Policy
+
+	// ForwardedHeaders selects the proxy headers added to upstream requests
+	ForwardedHeaders ForwardedHeaders
+
+	// ClientAuth is nil when mTLS is disabled
+	ClientAuth *ClientAuth
+
+	// Client sends the service's upstream requests
+	Client *fasthttp.Client
+	// Timeout bounds the whole request including retries, zero means no limit
+	Timeout time.Duration
+}
+
+// ForwardedHeaders selects the proxy headers added to upstream requests
+type ForwardedHeaders struct {
+	For       bool
+	Proto     bool
+	Host      bool
+	Prefix    bool
+	Forwarded bool
+	RequestID bool
+}
+
+// ClientAuth verifies client certificates for a service
+type ClientAuth struct {
+	// Roots is nil when the CA bundle could not be loaded, which rejects
+	// every certificate
+	Roots    *x509.CertPool
+	Required bool
+	// AllowedSubjects and AllowedSANs are compiled identity patterns; an
+	// empty list allows every certificate
+	AllowedSubjects []*regexp.Regexp
+	AllowedSANs     []*regexp.Regexp
+
+	SubjectHeader     string
+	SANHeader         string
+	FingerprintHeader string
+}
+
+// Pipeline holds every compiled service for one configuration snapshot
+type Pipeline struct {
+	snapshot *config.Snapshot
+	services map[string]*Service
+	// trustedProxies are the peers whose forwarding headers are honored
+	trustedProxies []*net.IPNet
+}
+
+// TrustedPeer reports whether the request came directly from a trusted proxy
+func (p *Pipeline) TrustedPeer(c *fiber.Ctx) bool {
+	return utils.ContainsIP(p.trustedProxies, c.Context().RemoteIP())
+}
+
+// Snapshot returns the configuration snapshot the pipeline was compiled from
+func (p *Pipeline) Snapshot() *config.Snapshot {
+	return p.snapshot
+}
+
+// Service returns the compiled service by name
+func (p *Pipeline) Service(name string) (*Service, bool) {
+	service, exists := p.services[name]
+	return service, exists
+}
+
+// Current returns the pipeline for the active configuration snapshot,
+// compiling a new one only when the snapshot has changed
+func Current() *Pipeline {
+	snapshot := config.Current()
+	if p := current.Load(); p != nil && p.snapshot == snapshot {
+		return p
+	}
+
+	compileMu.Lock()
+	defer compileMu.Unlock()
+
+	// Another request may have compiled it while we were waiting
+	if p := current.Load(); p != nil && p.snapshot == snapshot {
+		return p
+	}
+
+	p := Compile(snapshot)
+	current.Store(p)
+	return p
+}
+
+// Pin binds the current pipeline and its snapshot to the request so every
+// later handler sees the same configuration, even across a reload
+func Pin(c *fiber.Ctx) *Pipeline {
+	p := Current()
+	c.Locals(LocalsKey, p)
+	c.Locals(utils.SnapshotVersionLocalsKey, strconv.FormatUint(p.snapshot.Version(), 10))
+	c.Locals(utils.ClientIPLocalsKey, utils.ResolveClientIP(c, p.trustedProxies))
+	return p
+}
+
+// FromCtx returns the pipeline pinned to the request, pinning the current
+// one if none has been pinned yet
+func FromCtx(c *fiber.Ctx) *Pipeline {
+	if p, ok := c.Locals(LocalsKey).(*Pipeline); ok {
+		return p
+	}
+	return Pin(c)
+}
+
+// ServiceFromCtx returns the compiled service named by the :service route
+// parameter from the pinned pipeline
+func ServiceFromCtx(c *fiber.Ctx) (*Service, bool) {
+	if service, ok := c.Locals(ServiceLocalsKey).(*Service); ok {
+		return service, true
+	}
+
+	service, exists := FromCtx(c).Service(c.Params("service"))
+	if exists {
+		c.Locals(ServiceLocalsKey, service)
+	}
+	return service, exists
+}
+
+// Compile builds the request handling state of every service in snapshot
+func Compile(snapshot *config.Snapshot) *Pipeline {
+	cfg := snapshot.Config()
+	p := &Pipeline{
+		snapshot: snapshot,
+		services: make(map[string]*Service, len(cfg.Services)),
+	}
+	if cfg.Global != nil {
+		p.trustedProxies = parseNetworks(cfg.Global.TrustedProxies)
+	}
+
+	for name, service := range cfg.Services {
+		resolved := config.ResolveService(service, cfg.Global)
+		p.services[name] = compileService(name, &resolved)
+	}
+
+	return p
+}
+
+// compileService parses lists and constructs handlers for one service
+func compileService(name string, serviceConfig *config.ServiceConfig) *Service {
+	service := &Service{
+		Name:               name,
+		Config:             serviceConfig,
+		IPAllowList:        parseNetworks(serviceConfig.IPAllowList),
+		IPBlockList:        parseNetworks(serviceConfig.IPBlockList),
+		UserAgentAllowlist: lowerAll(serviceConfig.UserAgentAllowlist),
+		UserAgentBlocklist: lowerAll(serviceConfig.UserAgentBlocklist),
+		Upstream:           balancer.NewPool(name, serviceConfig.UpstreamTargets(), serviceConfig.LoadBalancing, serviceConfig.OutlierDetection),
+		Breaker:            breaker.New(name, serviceConfig.CircuitBreaker),
+		Retry:              retry.New(name, serviceConfig.Retry),
+		ForwardedHeaders:   newForwardedHeaders(serviceConfig.ForwardedHeaders),
+	}
+
+	if mtls := serviceConfig.MTLS; mtls != nil && mtls.Enabled != nil && *mtls.Enabled {
+		service.ClientAuth = newClientAuth(name, mtls)
+	}
+
+	if timeouts := serviceConfig.Timeouts; timeouts != nil {
+		service.Timeout = timeouts.Total
+	}
+	service.Client = newClient(name, serviceConfig.Timeouts, serviceConfig.UpstreamTLS)
+
+	if rateLimit := serviceConfig.RateLimit; rateLimit != nil && rateLimit.Enabled != nil && *rateLimit.Enabled &&
+		rateLimit.MaxRequests > 0 && rateLimit.Duration > 0 {
+		service.RateLimiter = newLimiter(name, rateLimit)
+	}
+
+	if cacheConfig := serviceConfig.Cache; cacheConfig != nil && cacheConfig.Enabled != nil && *cacheConfig.Enabled {
+		service.Cache = newCache(name, cacheConfig)
+	}
+
+	return service
+}
+
+// newClientAuth loads the CA bundle and applies the defaults to the mTLS
+// settings of a service
+func newClientAuth(name string, mtls *config.MTLSConfig) *ClientAuth {
+	clientAuth := &ClientAuth{
+		Required:          mtls.Mode != "optional",
+		AllowedSubjects:   compilePatterns(mtls.AllowedSubjects),
+		AllowedSANs:       compilePatterns(mtls.AllowedSANs),
+		SubjectHeader:     "X-Client-Cert-Subject",
+		SANHeader:         "X-Client-Cert-SAN",
+		FingerprintHeader: "X-Client-Cert-Fingerprint",
+	}
+
+	roots, err := config.LoadCertPool(mtls.CAFile)
+	if err != nil {
+		log.Printf("Service %s rejects all client certificates: %v", name, err)
+	}
+	clientAuth.Roots = roots
+
+	if headers := mtls.Headers; headers != nil {
+		if headers.Subject != "" {
+			clientAuth.SubjectHeader = headers.Subject
+		}
+		if headers.SAN != "" {
+			clientAuth.SANHeader = headers.SAN
+		}
+		if headers.Fingerprint != "" {
+			clientAuth.FingerprintHeader = headers.Fingerprint
+		}
+	}
+	return clientAuth
+}
+
+// neverMatch stands in for patterns that fail to compile so they deny
+// rather than drop out of the allow list
+var neverMatch = regexp.MustCompile(`[^\x00-\x{10FFFF}]`)
+
+// compilePatterns compiles the identity patterns of an mTLS setting
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		matcher, err := config.CompilePattern(pattern)
+		if err != nil {
+			log.Printf("Ignoring client certificate pattern: %v", err)
+			matcher = neverMatch
+		}
+		compiled = append(compiled, matcher)
+	}
+	return compiled
+}
+
+// newForwardedHeaders applies the defaults to the forwarded header settings
+func newForwardedHeaders(settings *config.ForwardedHeadersConfig) ForwardedHeaders {
+	if settings == nil {
+		settings = &config.ForwardedHeadersConfig{}
+	}
+	enabled := func(value *bool, fallback bool) bool {
+		if value == nil {
+			return fallback
+		}
+		return *value
+	}
+
+	return ForwardedHeaders{
+		For:       enabled(settings.XForwardedFor, true),
+		Proto:     enabled(settings.XForwardedProto, true),
+		Host:      enabled(settings.XForwardedHost, true),
+		Prefix:    enabled(settings.XForwardedPrefix, true),
+		Forwarded: enabled(settings.Forwarded, false),
+		RequestID: enabled(settings.RequestID, true),
+	}
+}
+
+// newClient builds the upstream client of a service with its own connect
+// and response timeouts and TLS settings. fasthttp reads the response
+// headers and body under one deadline, so response_header bounds reading the
+// whole response. Every service gets its own client so fasthttp's retries
+// stay disabled: they would bypass the retry policy and its budget and
+// silently multiply timeouts.
+func newClient(name string, timeouts *config.TimeoutConfig, upstreamTLS *config.UpstreamTLSConfig) *fasthttp.Client {
+	var connect, responseHeader time.Duration
+	if timeouts != nil {
+		connect, responseHeader = timeouts.Connect, timeouts.ResponseHeader
+	}
+
+	client := &fasthttp.Client{
+		NoDefaultUserAgentHeader:  true,
+		DisablePathNormalizing:    true,
+		ReadTimeout:               responseHeader,
+		MaxIdemponentCallAttempts: 1,
+	}
+	if connect > 0 {
+		client.Dial = func(addr string) (net.Conn, error) {
+			return fasthttp.DialTimeout(addr, connect)
+		}
+	}
+	if upstreamTLS != nil {
+		client.TLSConfig = newUpstreamTLS(name, upstreamTLS)
+	}
+	return client
+}
+
+// newUpstreamTLS builds the TLS configuration used towards the service's
+// upstream. When its files cannot be loaded every upstream certificate is
+// rejected rather than falling back to the system roots.
+func newUpstreamTLS(name string, settings *config.UpstreamTLSConfig) *tls.Config {
+	tlsConfig, err := settings.ClientTLSConfig()
+	if err != nil {
+		log.Printf("Service %s rejects all upstream certificates: %v", name, err)
+		return &tls.Config{RootCAs: x509.NewCertPool(), MinVersion: config.TLSVersion(settings.MinVersion)}
+	}
+	return tlsConfig
+}
+
+// newLimiter builds the rate limiter handler for a service
+func newLimiter(name string, rateLimit *config.RateLimitConfig) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:        rateLimit.MaxRequests,
+		Expiration: rateLimit.Duration,
+		Storage:    limiterStore,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return name + "_" + utils.GetClientIP(c) // Use consistent IP detection
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			response := httpx.TooManyRequests("Rate limit exceeded")
+			return httpx.SendResponse(c, response)
+		},
+	})
+}
+
+// newCache builds the response cache handler for a service
+func newCache(name string, cacheConfig *config.CacheConfig) fiber.Handler {
+	return cache.New(cache.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return c.Method() != "GET" // Only cache GET requests
+		},
+		Expiration: cacheConfig.Duration,
+		Storage:    cacheStore,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return name + "_" + c.Path() + string(c.OriginalURL())
+		},
+	})
+}
+
+// parseNetworks parses IP and CIDR entries, skipping invalid ones that
+// config validation would already have rejected
+func parseNetworks(entries []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if network, err := config.ParseNetwork(entry); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// lowerAll returns a lowercased copy of values
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
@@ -0,0 +1,261 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// v2Signature starts every PROXY protocol v2 header
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// v1MaxLength is the longest possible v1 header including CRLF
+const v1MaxLength = 107
+
+// ErrInvalidHeader is returned when a trusted peer does not start the
+// connection with a valid PROXY protocol header
+var ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+// Listener wraps a listener so connections from trusted sources report the
+// client address carried in their PROXY protocol header. Connections from
+// other sources are passed through unchanged, so they cannot spoof it.
+type Listener struct {
+	net.Listener
+	trusted       []*net.IPNet
+	headerTimeout time.Duration
+}
+
+// NewListener wraps ln, reading PROXY protocol v1 and v2 headers from
+// connections whose source is inside one of the trusted networks
+func NewListener(ln net.Listener, trusted []*net.IPNet, headerTimeout time.Duration) *Listener {
+	return &Listener{Listener: ln, trusted: trusted, headerTimeout: headerTimeout}
+}
+
+// Accept waits for the next connection. The header is read lazily on first
+// use so a slow client cannot block the accept loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); !ok || !l.isTrusted(addr.IP) {
+		return conn, nil
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), headerTimeout: l.headerTimeout}, nil
+}
+
+func (l *Listener) isTrusted(ip net.IP) bool {
+	for _, network := range l.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection from a trusted source that starts with a PROXY
+// protocol header
+type Conn struct {
+	net.Conn
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+
+	once       sync.Once
+	headerErr  error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+
+	// readDeadline is the deadline set by the caller, which still applies
+	// once the header has been read
+	deadlineMu   sync.Mutex
+	readDeadline time.Time
+}
+
+// SetDeadline sets the read and write deadlines, remembering the read
+// deadline so reading the header does not discard it
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline, remembering it so reading the
+// header does not discard it
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+// Read reads application data following the PROXY protocol header
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.readHeader(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the header, or the peer
+// address for LOCAL and UNKNOWN headers
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.readHeader() != nil || c.remoteAddr == nil {
+		return c.Conn.RemoteAddr()
+	}
+	return c.remoteAddr
+}
+
+// LocalAddr returns the destination address from the header, or the local
+// address for LOCAL and UNKNOWN headers
+func (c *Conn) LocalAddr() net.Addr {
+	if c.readHeader() != nil || c.localAddr == nil {
+		return c.Conn.LocalAddr()
+	}
+	return c.localAddr
+}
+
+// readHeader parses the header once, bounded by the header timeout or the
+// caller's earlier read deadline, which is restored afterwards
+func (c *Conn) readHeader() error {
+	c.once.Do(func() {
+		if c.headerTimeout > 0 {
+			c.deadlineMu.Lock()
+			deadline := time.Now().Add(c.headerTimeout)
+			if !c.readDeadline.IsZero() && c.readDeadline.Before(deadline) {
+				deadline = c.readDeadline
+			}
+			c.Conn.SetReadDeadline(deadline)
+			c.deadlineMu.Unlock()
+
+			defer func() {
+				c.deadlineMu.Lock()
+				defer c.deadlineMu.Unlock()
+				c.Conn.SetReadDeadline(c.readDeadline)
+			}()
+		}
+
+		c.headerErr = c.parse()
+		if c.headerErr != nil {
+			c.Conn.Close()
+		}
+	})
+	return c.headerErr
+}
+
+// parse detects the header version and reads it
+func (c *Conn) parse() error {
+	// The shortest v1 header ("PROXY UNKNOWN\r\n") is longer than the v2 signature
+	start, err := c.reader.Peek(len(v2Signature))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+
+	switch {
+	case bytes.Equal(start, v2Signature):
+		return c.parseV2()
+	case bytes.HasPrefix(start, []byte("PROXY ")):
+		return c.parseV1()
+	default:
+		return ErrInvalidHeader
+	}
+}
+
+// parseV1 reads a text header such as "PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n"
+func (c *Conn) parseV1() error {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		}
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return fmt.Errorf("%w: v1 header too long", ErrInvalidHeader)
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return fmt.Errorf("%w: malformed v1 header", ErrInvalidHeader)
+	}
+
+	src, srcErr := v1Addr(fields[2], fields[4])
+	dst, dstErr := v1Addr(fields[3], fields[5])
+	if srcErr != nil || dstErr != nil {
+		return fmt.Errorf("%w: malformed v1 address", ErrInvalidHeader)
+	}
+	c.remoteAddr, c.localAddr = src, dst
+	return nil
+}
+
+// v1Addr parses an address and port field of a v1 header
+func v1Addr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// parseV2 reads a binary header
+func (c *Conn) parseV2() error {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+
+	version, command := header[12]>>4, header[12]&0x0F
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+	if version != 2 || command > 1 {
+		return fmt.Errorf("%w: unsupported v2 version or command", ErrInvalidHeader)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+
+	// LOCAL connections (e.g. balancer health checks) keep the peer address
+	if command == 0 {
+		return nil
+	}
+
+	switch family >> 4 {
+	case 0x1: // IPv4
+		if len(payload) < 12 {
+			return fmt.Errorf("%w: short v2 IPv4 address", ErrInvalidHeader)
+		}
+		c.remoteAddr = &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		c.localAddr = &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+	case 0x2: // IPv6
+		if len(payload) < 36 {
+			return fmt.Errorf("%w: short v2 IPv6 address", ErrInvalidHeader)
+		}
+		c.remoteAddr = &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		c.localAddr = &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+	default:
+		// Unix sockets and unspecified families keep the peer address
+	}
+	return nil
+}
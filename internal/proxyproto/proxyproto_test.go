@@ -0,0 +1,283 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPipeConn returns a Conn reading the header from the client end of a pipe
+func newPipeConn(headerTimeout time.Duration) (*Conn, net.Conn) {
+	server, client := net.Pipe()
+	return &Conn{Conn: server, reader: bufio.NewReader(server), headerTimeout: headerTimeout}, client
+}
+
+// v2Header builds a v2 header with the given command, family and payload
+func v2Header(command, family byte, payload []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	return append(header, payload...)
+}
+
+// v2Addresses builds the address block of a v2 header
+func v2Addresses(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	payload := append(append([]byte{}, src...), dst...)
+	payload = binary.BigEndian.AppendUint16(payload, srcPort)
+	return binary.BigEndian.AppendUint16(payload, dstPort)
+}
+
+func TestConnParsesHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []byte
+		wantRemote string // empty keeps the peer address
+		wantLocal  string
+		wantErr    bool
+	}{
+		{
+			name:       "v1 TCP4",
+			input:      []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"),
+			wantRemote: "192.0.2.1:56324",
+			wantLocal:  "198.51.100.1:443",
+		},
+		{
+			name:       "v1 TCP6",
+			input:      []byte("PROXY TCP6 2001:db8::1 2001:db8::2 1000 443\r\n"),
+			wantRemote: "[2001:db8::1]:1000",
+			wantLocal:  "[2001:db8::2]:443",
+		},
+		{
+			name:  "v1 UNKNOWN",
+			input: []byte("PROXY UNKNOWN\r\n"),
+		},
+		{
+			name:  "v1 UNKNOWN with addresses",
+			input: []byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n"),
+		},
+		{
+			name:    "v1 too long",
+			input:   []byte("PROXY TCP4 " + strings.Repeat("1", v1MaxLength) + "\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 truncated",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 missing fields",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 invalid address",
+			input:   []byte("PROXY TCP4 192.0.2.x 198.51.100.1 56324 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 invalid port",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1 70000 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 unsupported protocol",
+			input:   []byte("PROXY UDP4 192.0.2.1 198.51.100.1 56324 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:  "v2 LOCAL",
+			input: v2Header(0x0, 0x00, nil),
+		},
+		{
+			name:  "v2 LOCAL with addresses",
+			input: v2Header(0x0, 0x11, v2Addresses(net.IPv4(192, 0, 2, 1).To4(), net.IPv4(198, 51, 100, 1).To4(), 1, 2)),
+		},
+		{
+			name:       "v2 IPv4",
+			input:      v2Header(0x1, 0x11, v2Addresses(net.IPv4(192, 0, 2, 1).To4(), net.IPv4(198, 51, 100, 1).To4(), 56324, 443)),
+			wantRemote: "192.0.2.1:56324",
+			wantLocal:  "198.51.100.1:443",
+		},
+		{
+			name:       "v2 IPv6",
+			input:      v2Header(0x1, 0x21, v2Addresses(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 1000, 443)),
+			wantRemote: "[2001:db8::1]:1000",
+			wantLocal:  "[2001:db8::2]:443",
+		},
+		{
+			name:       "v2 IPv4 with TLVs",
+			input:      v2Header(0x1, 0x11, append(v2Addresses(net.IPv4(192, 0, 2, 1).To4(), net.IPv4(198, 51, 100, 1).To4(), 56324, 443), 0x04, 0x00, 0x01, 0xff)),
+			wantRemote: "192.0.2.1:56324",
+			wantLocal:  "198.51.100.1:443",
+		},
+		{
+			name:  "v2 unix socket",
+			input: v2Header(0x1, 0x31, make([]byte, 216)),
+		},
+		{
+			name:    "v2 short IPv4 address",
+			input:   v2Header(0x1, 0x11, make([]byte, 8)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 short IPv6 address",
+			input:   v2Header(0x1, 0x21, make([]byte, 12)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 truncated payload",
+			input:   v2Header(0x1, 0x11, make([]byte, 12))[:20],
+			wantErr: true,
+		},
+		{
+			name:    "v2 truncated header",
+			input:   v2Signature[:8],
+			wantErr: true,
+		},
+		{
+			name:    "v2 unsupported command",
+			input:   v2Header(0x2, 0x11, make([]byte, 12)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 unsupported version",
+			input:   append(append([]byte{}, v2Signature...), 0x11, 0x11, 0x00, 0x00),
+			wantErr: true,
+		},
+		{
+			name:    "no header",
+			input:   []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := newPipeConn(time.Second)
+			defer conn.Close()
+
+			payload := []byte("GET / HTTP/1.1\r\n")
+			go func() {
+				client.Write(tt.input)
+				if !tt.wantErr {
+					client.Write(payload)
+				}
+				client.Close()
+			}()
+
+			if tt.wantErr {
+				if _, err := conn.Read(make([]byte, 64)); !errors.Is(err, ErrInvalidHeader) {
+					t.Fatalf("Read() error = %v, want %v", err, ErrInvalidHeader)
+				}
+				return
+			}
+
+			data, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !bytes.Equal(data, payload) {
+				t.Errorf("Read() = %q, want %q", data, payload)
+			}
+
+			wantRemote, wantLocal := tt.wantRemote, tt.wantLocal
+			if wantRemote == "" {
+				wantRemote, wantLocal = conn.Conn.RemoteAddr().String(), conn.Conn.LocalAddr().String()
+			}
+			if got := conn.RemoteAddr().String(); got != wantRemote {
+				t.Errorf("RemoteAddr() = %s, want %s", got, wantRemote)
+			}
+			if got := conn.LocalAddr().String(); got != wantLocal {
+				t.Errorf("LocalAddr() = %s, want %s", got, wantLocal)
+			}
+		})
+	}
+}
+
+func TestConnKeepsCallerReadDeadline(t *testing.T) {
+	conn, client := newPipeConn(5 * time.Second)
+	defer conn.Close()
+	defer client.Close()
+
+	// A valid header followed by a client that never sends its request
+	go client.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"))
+
+	if err := conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err := conn.Read(make([]byte, 64))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read() error = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Read() returned after %s, want the 300ms caller deadline", elapsed)
+	}
+}
+
+func TestConnHeaderTimeout(t *testing.T) {
+	conn, client := newPipeConn(200 * time.Millisecond)
+	defer conn.Close()
+	defer client.Close()
+
+	// The caller's later deadline does not extend the header timeout
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 64)); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("Read() error = %v, want %v", err, ErrInvalidHeader)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Read() returned after %s, want the 200ms header timeout", elapsed)
+	}
+}
+
+func TestListenerOnlyTrustsConfiguredSources(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    string
+		wantRemote string
+	}{
+		{"trusted source", "127.0.0.0/8", "192.0.2.1"},
+		{"untrusted source", "10.0.0.0/8", "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, network, _ := net.ParseCIDR(tt.trusted)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxied := NewListener(ln, []*net.IPNet{network}, time.Second)
+			defer proxied.Close()
+
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			go client.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"))
+
+			conn, err := proxied.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			if host != tt.wantRemote {
+				t.Errorf("RemoteAddr() host = %s, want %s", host, tt.wantRemote)
+			}
+		})
+	}
+}
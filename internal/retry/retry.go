@@ -0,0 +1,216 @@
+package retry
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/upstream"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Conditions accepted in retry.retry_on
+const (
+	ConnectError = "connect_error"
+	Timeout      = "timeout"
+)
+
+// Defaults applied to unset retry settings
+const (
+	defaultMaxAttempts   = 3
+	defaultBackoffBase   = 25 * time.Millisecond
+	defaultBackoffMax    = 250 * time.Millisecond
+	defaultBudgetPercent = 20
+	defaultMinRetries    = 3
+)
+
+// budgetWindow is the period over which the retry budget is accounted
+const budgetWindow = 10 * time.Second
+
+// defaultRetryOn and defaultMethods are used when retry_on or methods are unset
+var (
+	defaultRetryOn = []string{ConnectError}
+	defaultMethods = []string{
+		fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodOptions,
+		fasthttp.MethodPut, fasthttp.MethodDelete, fasthttp.MethodTrace,
+	}
+)
+
+// budget caps retries as a share of the requests sent to a service. It is
+// kept across config reloads like rate limit counters.
+type budget struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+// Policy is a service's retry policy built for one configuration snapshot
+type Policy struct {
+	maxAttempts   int
+	retryOn       map[string]bool
+	statusCodes   map[int]bool
+	methods       map[string]bool
+	backoffBase   time.Duration
+	backoffMax    time.Duration
+	budgetPercent int
+	minRetries    int
+
+	budget *budget
+}
+
+var (
+	// budgets holds the retry budget of every service keyed by name
+	budgets   = make(map[string]*budget)
+	budgetsMu sync.Mutex
+)
+
+// New builds the retry policy of a service, returning nil when retries are
+// disabled. A nil *Policy never retries.
+func New(service string, settings *config.RetryConfig) *Policy {
+	if settings == nil || settings.Enabled == nil || !*settings.Enabled {
+		return nil
+	}
+
+	p := &Policy{
+		maxAttempts:   settings.MaxAttempts,
+		retryOn:       make(map[string]bool),
+		statusCodes:   make(map[int]bool),
+		methods:       make(map[string]bool),
+		backoffBase:   settings.BackoffBase,
+		backoffMax:    settings.BackoffMax,
+		budgetPercent: settings.BudgetPercent,
+		minRetries:    settings.MinRetries,
+		budget:        budgetFor(service),
+	}
+	if p.maxAttempts == 0 {
+		p.maxAttempts = defaultMaxAttempts
+	}
+	if p.backoffBase == 0 {
+		p.backoffBase = defaultBackoffBase
+	}
+	if p.backoffMax == 0 {
+		p.backoffMax = max(defaultBackoffMax, p.backoffBase)
+	}
+	if p.budgetPercent == 0 {
+		p.budgetPercent = defaultBudgetPercent
+	}
+	if p.minRetries == 0 {
+		p.minRetries = defaultMinRetries
+	}
+
+	retryOn, methods := settings.RetryOn, settings.Methods
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+	if len(methods) == 0 {
+		methods = defaultMethods
+	}
+	for _, condition := range retryOn {
+		p.retryOn[condition] = true
+	}
+	for _, method := range methods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+	for _, code := range settings.StatusCodes {
+		p.statusCodes[code] = true
+	}
+	return p
+}
+
+// budgetFor returns the shared retry budget of a service, creating it on first use
+func budgetFor(service string) *budget {
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+
+	b, exists := budgets[service]
+	if !exists {
+		b = &budget{windowStart: time.Now()}
+		budgets[service] = b
+	}
+	return b
+}
+
+// Begin counts a request towards the retry budget. It must be called once
+// per client request, before any attempt is made.
+func (p *Policy) Begin() {
+	if p == nil {
+		return
+	}
+
+	b := p.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resetExpired(time.Now())
+	b.requests++
+}
+
+// ShouldRetry reports whether a request should be attempted again after
+// attempt failed with err or answered with status. A retry is only granted
+// when the retry budget allows it, and it is then counted against the budget.
+func (p *Policy) ShouldRetry(method string, attempt int, err error, status int) bool {
+	if p == nil || attempt >= p.maxAttempts || !p.methods[method] {
+		return false
+	}
+
+	switch {
+	case err != nil:
+		if !p.retryOn[condition(err)] {
+			return false
+		}
+	case !p.statusCodes[status]:
+		return false
+	}
+
+	return p.budget.take(time.Now(), p.budgetPercent, p.minRetries)
+}
+
+// Backoff returns how long to wait before the next attempt, using
+// exponential backoff with full jitter
+func (p *Policy) Backoff(attempt int) time.Duration {
+	ceiling := p.backoffBase
+	for i := 1; i < attempt && ceiling < p.backoffMax; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, p.backoffMax)
+	return rand.N(ceiling + 1)
+}
+
+// condition maps a transport error to the retry_on condition it matches, or
+// "" for errors that are never retried, such as TLS verification failures
+// and invalid responses
+func condition(err error) string {
+	switch upstream.Classify(err) {
+	case upstream.CodeTimeout:
+		return Timeout
+	case upstream.CodeUnreachable, upstream.CodeDNS, upstream.CodeConnectionReset:
+		return ConnectError
+	default:
+		return ""
+	}
+}
+
+// take grants a retry when the retries in the current window stay within
+// percent of the requests, or below minRetries
+func (b *budget) take(now time.Time, percent, minRetries int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resetExpired(now)
+	if b.retries >= max(minRetries, b.requests*percent/100) {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+// resetExpired starts a new accounting window once the current one is over.
+// The caller must hold the budget lock.
+func (b *budget) resetExpired(now time.Time) {
+	if now.Sub(b.windowStart) >= budgetWindow {
+		b.windowStart, b.requests, b.retries = now, 0, 0
+	}
+}
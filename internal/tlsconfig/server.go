@@ -0,0 +1,83 @@
+package tlsconfig
+
+import (
+	"api-gateway/internal/config"
+	"crypto/tls"
+	"errors"
+	"log"
+	"sync/atomic"
+)
+
+// server is the TLS configuration built from the active snapshot
+var server atomic.Pointer[tls.Config]
+
+// Enabled reports whether snapshot turns on HTTPS termination
+func Enabled(snapshot *config.Snapshot) bool {
+	settings := snapshot.Config().TLS
+	return settings != nil && settings.Enabled != nil && *settings.Enabled
+}
+
+// Sync rebuilds the server TLS configuration from snapshot. When the
+// certificates cannot be loaded the previous configuration stays in use.
+func Sync(snapshot *config.Snapshot) {
+	if !Enabled(snapshot) {
+		return
+	}
+
+	tlsConfig, err := build(snapshot.Config())
+	if err != nil {
+		log.Printf("Failed to update TLS certificates, keeping the previous ones: %v", err)
+		return
+	}
+	server.Store(tlsConfig)
+}
+
+// ServerConfig returns a TLS configuration for the gateway listener that
+// always serves the certificates of the most recent snapshot
+func ServerConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			tlsConfig := server.Load()
+			if tlsConfig == nil {
+				return nil, errors.New("no TLS certificates loaded")
+			}
+			return tlsConfig, nil
+		},
+	}
+}
+
+// build loads the certificates and settings of the tls section. With
+// several certificates crypto/tls selects the one matching the SNI server
+// name, falling back to the first. Client certificates are requested when
+// a service uses mTLS; they are verified per service since the service is
+// only known once the request has been read.
+func build(cfg *config.MainConfig) (*tls.Config, error) {
+	settings := cfg.TLS
+	tlsConfig := &tls.Config{
+		MinVersion: config.TLSVersion(settings.MinVersion),
+		NextProtos: []string{"http/1.1"},
+	}
+
+	for _, service := range cfg.Services {
+		if mtls := service.MTLS; mtls != nil && mtls.Enabled != nil && *mtls.Enabled {
+			tlsConfig.ClientAuth = tls.RequestClientCert
+			break
+		}
+	}
+
+	for _, certificate := range settings.Certificates {
+		loaded, err := certificate.Load()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, loaded)
+	}
+
+	for _, name := range settings.CipherSuites {
+		if id, ok := config.CipherSuite(name); ok {
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	return tlsConfig, nil
+}
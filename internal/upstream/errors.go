@@ -0,0 +1,59 @@
+package upstream
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Error codes reported when forwarding a request to an upstream fails
+const (
+	CodeTimeout         = "UPSTREAM_TIMEOUT"
+	CodeDNS             = "UPSTREAM_DNS"
+	CodeUnreachable     = "UPSTREAM_UNREACHABLE"
+	CodeTLS             = "UPSTREAM_TLS"
+	CodeConnectionReset = "UPSTREAM_CONNECTION_RESET"
+	CodeBadResponse     = "UPSTREAM_BAD_RESPONSE"
+	CodeError           = "UPSTREAM_ERROR"
+)
+
+// Classify maps an error returned while forwarding a request to its error code
+func Classify(err error) string {
+	var (
+		netErr         net.Error
+		dnsErr         *net.DNSError
+		opErr          *net.OpError
+		recordErr      tls.RecordHeaderError
+		alertErr       tls.AlertError
+		verifyErr      *tls.CertificateVerificationError
+		authorityErr   x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certInvalidErr x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.Is(err, fasthttp.ErrTimeout), errors.Is(err, fasthttp.ErrDialTimeout),
+		errors.Is(err, fasthttp.ErrTLSHandshakeTimeout), errors.As(err, &netErr) && netErr.Timeout():
+		return CodeTimeout
+	case errors.As(err, &dnsErr):
+		return CodeDNS
+	case errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &certInvalidErr):
+		return CodeTLS
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH), errors.As(err, &opErr) && opErr.Op == "dial":
+		return CodeUnreachable
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, fasthttp.ErrConnectionClosed):
+		return CodeConnectionReset
+	case errors.Is(err, fasthttp.ErrBodyTooLarge):
+		return CodeBadResponse
+	default:
+		return CodeError
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientIPLocalsKey holds the client IP resolved for the request
+const ClientIPLocalsKey = "client_ip"
+
+// ResolveClientIP returns the address of the client that sent the request.
+// Forwarding headers are only honored when the immediate peer is a trusted
+// proxy: X-Forwarded-For is walked right to left and the first address that
+// is not a trusted proxy is the client, falling back to X-Real-IP.
+func ResolveClientIP(c *fiber.Ctx, trusted []*net.IPNet) string {
+	peer := c.Context().RemoteIP()
+	if !ContainsIP(trusted, peer) {
+		return peer.String()
+	}
+
+	var hops []string
+	for _, value := range c.Request().Header.PeekAll(fiber.HeaderXForwardedFor) {
+		hops = append(hops, strings.Split(string(value), ",")...)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == nil {
+			// A malformed entry cannot be trusted; the hop that added it is
+			// the furthest address we know
+			break
+		}
+		client = ip
+		if !ContainsIP(trusted, ip) {
+			return ip.String()
+		}
+	}
+	if len(hops) > 0 {
+		return client.String()
+	}
+
+	if ip := parseHop(c.Get("X-Real-IP")); ip != nil {
+		return ip.String()
+	}
+	return peer.String()
+}
+
+// GetClientIP returns the client IP resolved for the request, falling back
+// to the peer address when none has been resolved
+func GetClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals(ClientIPLocalsKey).(string); ok {
+		return ip
+	}
+	return c.Context().RemoteIP().String()
+}
+
+// ContainsIP reports whether ip is inside any of the networks
+func ContainsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHop parses one forwarding header entry, which may carry a port
+func parseHop(value string) net.IP {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	return net.ParseIP(strings.Trim(value, "[]"))
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted := []*net.IPNet{
+		mustNetwork(t, "10.0.0.0/8"),
+		mustNetwork(t, "::1/128"),
+	}
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name: "untrusted peer ignores headers",
+			peer: "203.0.113.7",
+			headers: map[string][]string{
+				fiber.HeaderXForwardedFor: {"198.51.100.1"},
+				"X-Real-IP":               {"198.51.100.2"},
+			},
+			want: "203.0.113.7",
+		},
+		{
+			name: "trusted peer without headers",
+			peer: "10.0.0.1",
+			want: "10.0.0.1",
+		},
+		{
+			name:    "single hop",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "rightmost untrusted hop wins over spoofed entries",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"1.2.3.4, 198.51.100.1, 10.0.0.2"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "repeated headers are joined in order",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"1.2.3.4", "198.51.100.1, 10.0.0.2"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "all hops trusted yields the leftmost",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"10.0.0.3, 10.0.0.2"}},
+			want:    "10.0.0.3",
+		},
+		{
+			name:    "malformed hop stops the walk",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"198.51.100.1, bogus, 10.0.0.2"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "malformed last hop keeps the peer",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"198.51.100.1, not-an-ip"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "hops with ports and IPv6 brackets",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"[2001:db8::1]:443, 10.0.0.2:8080"}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "X-Real-IP fallback",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Real-IP": {"198.51.100.9"}},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "X-Forwarded-For takes precedence over X-Real-IP",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"198.51.100.1"}, "X-Real-IP": {"198.51.100.9"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "malformed X-Real-IP keeps the peer",
+			peer:    "10.0.0.1",
+			headers: map[string][]string{"X-Real-IP": {"nope"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "trusted IPv6 peer",
+			peer:    "::1",
+			headers: map[string][]string{fiber.HeaderXForwardedFor: {"2001:db8::2"}},
+			want:    "2001:db8::2",
+		},
+	}
+
+	app := fiber.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req fasthttp.Request
+			for key, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+			var fctx fasthttp.RequestCtx
+			fctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(tt.peer), Port: 40000}, nil)
+
+			c := app.AcquireCtx(&fctx)
+			defer app.ReleaseCtx(c)
+
+			if got := ResolveClientIP(c, trusted); got != tt.want {
+				t.Errorf("ResolveClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustNetwork(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
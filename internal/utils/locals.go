@@ -0,0 +1,8 @@
+package utils
+
+const (
+	// SnapshotVersionLocalsKey holds the pinned snapshot version as a string for logging
+	SnapshotVersionLocalsKey = "config_version"
+	// RequestIDLocalsKey holds the request ID generated by the requestid middleware
+	RequestIDLocalsKey = "requestid"
+)
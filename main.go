@@ -1,15 +1,28 @@
 package main
 
 import (
+	"api-gateway/internal/breaker"
 	"api-gateway/internal/config"
 	"api-gateway/internal/constants"
 	"api-gateway/internal/handlers"
+	"api-gateway/internal/health"
 	"api-gateway/internal/middleware"
+	"api-gateway/internal/pipeline"
+	"api-gateway/internal/proxyproto"
+	"api-gateway/internal/tlsconfig"
+	"api-gateway/internal/utils"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
@@ -23,20 +36,44 @@ import (
 	pkgValidator "github.com/kerimovok/go-pkg-utils/validator"
 )
 
-func init() {
-	// Load configuration
-	if err := config.LoadConfig(); err != nil {
-		log.Fatalf("failed to load config: %v", err)
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	// Validate environment variables
-	if err := pkgValidator.ValidateConfig(constants.EnvValidationRules); err != nil {
-		log.Fatalf("configuration validation failed: %v", err)
+	switch command {
+	case "serve":
+		serve(args)
+	case "validate":
+		os.Exit(validateCommand(args))
+	case "schema":
+		os.Exit(schemaCommand(args))
+	case "help":
+		printUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		printUsage()
+		os.Exit(2)
 	}
 }
 
 func setupApp() *fiber.App {
-	app := fiber.New(fiber.Config{})
+	// Bound slow clients; the values are validated on startup
+	readTimeout, _ := time.ParseDuration(pkgConfig.GetEnvOrDefault("SERVER_READ_TIMEOUT", "30s"))
+	writeTimeout, _ := time.ParseDuration(pkgConfig.GetEnvOrDefault("SERVER_WRITE_TIMEOUT", "30s"))
+	idleTimeout, _ := time.ParseDuration(pkgConfig.GetEnvOrDefault("SERVER_IDLE_TIMEOUT", "120s"))
+	bodyLimit, _ := strconv.Atoi(pkgConfig.GetEnvOrDefault("SERVER_BODY_LIMIT", "4194304"))
+
+	app := fiber.New(fiber.Config{
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+		BodyLimit:    bodyLimit,
+	})
+
+	// Pin one configuration snapshot for the whole request
+	app.Use(middleware.ConfigSnapshotMiddleware())
 
 	// Middleware
 	app.Use(helmet.New())
@@ -44,18 +81,27 @@ func setupApp() *fiber.App {
 	app.Use(compress.New())
 	app.Use(healthcheck.New())
 	app.Use(requestid.New(requestid.Config{
+		ContextKey: utils.RequestIDLocalsKey,
 		Generator: func() string {
 			return uuid.New().String()
 		},
 	}))
 
 	// Enable logging middleware based on global configuration
+	requestLogger := logger.New(logger.Config{
+		CustomTags: map[string]logger.LogFunc{
+			utils.ClientIPLocalsKey: func(output logger.Buffer, c *fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+				return output.WriteString(utils.GetClientIP(c))
+			},
+		},
+		Format: "${time} | ${status} | ${latency} | ${client_ip} | ${method} | ${path} | config v${locals:" + utils.SnapshotVersionLocalsKey + "} | ${error}\n",
+	})
 	app.Use(func(c *fiber.Ctx) error {
-		cfg := config.GetConfig()
+		cfg := pipeline.FromCtx(c).Snapshot().Config()
 
 		// Only enable logging if global logging is enabled
 		if cfg.Global != nil && cfg.Global.Logging != nil && *cfg.Global.Logging {
-			return logger.New()(c)
+			return requestLogger(c)
 		}
 
 		return c.Next()
@@ -64,25 +110,171 @@ func setupApp() *fiber.App {
 	return app
 }
 
-func main() {
+// setupAdminApp builds the admin API used to inspect and change the live
+// configuration
+func setupAdminApp(token string) *fiber.App {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		// Request values such as service names end up in the config
+		Immutable: true,
+	})
+
+	app.Use(helmet.New())
+	app.Use(middleware.AdminAuthMiddleware(token))
+
+	app.Get("/config", handlers.AdminGetConfigHandler())
+	app.Get("/services", handlers.AdminListServicesHandler())
+	app.Get("/services/:name", handlers.AdminGetServiceHandler())
+	app.Put("/services/:name", handlers.AdminPutServiceHandler())
+	app.Delete("/services/:name", handlers.AdminDeleteServiceHandler())
+	app.Get("/global", handlers.AdminGetGlobalHandler())
+	app.Put("/global", handlers.AdminPutGlobalHandler())
+	app.Get("/upstreams", handlers.AdminUpstreamsHandler())
+	app.Get("/circuit-breakers", handlers.AdminCircuitBreakersHandler())
+	app.Get("/circuit-breakers/events", handlers.AdminCircuitBreakerEventsHandler())
+
+	return app
+}
+
+// setupRedirectApp builds a listener that redirects every request to the
+// same URL on the HTTPS port
+func setupRedirectApp(httpsPort string) *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+
+	app.Use(func(c *fiber.Ctx) error {
+		host := c.Hostname()
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		return c.Redirect("https://"+host+c.OriginalURL(), fiber.StatusPermanentRedirect)
+	})
+
+	return app
+}
+
+// listen opens the gateway listener, reading PROXY protocol headers from
+// trusted load balancers when enabled
+func listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if !pkgConfig.GetEnvBool("PROXY_PROTOCOL", false) {
+		return ln, nil
+	}
+
+	var trusted []*net.IPNet
+	for _, entry := range strings.Split(pkgConfig.GetEnv("PROXY_PROTOCOL_TRUSTED_CIDRS"), ",") {
+		network, err := config.ParseNetwork(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+		trusted = append(trusted, network)
+	}
+	headerTimeout, _ := time.ParseDuration(pkgConfig.GetEnvOrDefault("PROXY_PROTOCOL_HEADER_TIMEOUT", "5s"))
+
+	log.Printf("PROXY protocol enabled for %s", pkgConfig.GetEnv("PROXY_PROTOCOL_TRUSTED_CIDRS"))
+	return proxyproto.NewListener(ln, trusted, headerTimeout), nil
+}
+
+// serve loads the configuration and runs the gateway until interrupted
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFlag := flags.String("config", "", "path to the main config file (overrides CONFIG_PATH)")
+	flags.Parse(args)
+
+	config.LoadEnv()
+
+	// Load configuration
+	if err := config.LoadConfig(*configFlag); err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	// Validate environment variables
+	if err := pkgValidator.ValidateConfig(constants.EnvValidationRules); err != nil {
+		log.Fatalf("configuration validation failed: %v", err)
+	}
+
+	// Compile the request pipeline up front instead of on the first request
+	pipeline.Current()
+
+	// Serve HTTPS with the certificates of the latest config. Turning TLS on
+	// or off only takes effect after a restart.
+	tlsEnabled := tlsconfig.Enabled(config.Current())
+	tlsconfig.Sync(config.Current())
+	config.OnChange(func(snapshot *config.Snapshot) {
+		tlsconfig.Sync(snapshot)
+		if tlsconfig.Enabled(snapshot) != tlsEnabled {
+			log.Println("Warning: changing tls.enabled requires a restart")
+		}
+	})
+
+	// Run active health checks and keep them in sync with config changes
+	health.Sync(config.Current())
+	config.OnChange(health.Sync)
+
+	// Keep circuit breaker state changes for the admin API
+	breaker.OnStateChange(handlers.RecordBreakerEvent)
+
 	app := setupApp()
 
 	// Create channel for shutdown signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
+	// Reload configuration on file changes and SIGHUP
+	stopWatcher := make(chan struct{})
+	watchInterval, _ := time.ParseDuration(pkgConfig.GetEnvOrDefault("CONFIG_WATCH_INTERVAL", "5s"))
+	go config.WatchConfig(watchInterval, stopWatcher)
+
 	// Set up a dynamic route to proxy requests
 	app.All("/:service/*",
 		middleware.IPFilterMiddleware(),
+		middleware.ClientCertMiddleware(),
 		middleware.UserAgentFilter(),
 		middleware.APIKeyMiddleware(),
 		middleware.RateLimitMiddleware(),
 		middleware.CacheMiddleware(),
 		handlers.ProxyHandler())
 
+	// Start the admin API on its own listener when enabled
+	var adminApp *fiber.App
+	if adminPort := pkgConfig.GetEnv("ADMIN_PORT"); adminPort != "" {
+		adminApp = setupAdminApp(pkgConfig.GetEnv("ADMIN_TOKEN"))
+		go func() {
+			if err := adminApp.Listen(":" + adminPort); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("failed to start admin server: %v", err)
+			}
+		}()
+	}
+
+	ln, err := listen(":" + pkgConfig.GetEnv("PORT"))
+	if err != nil {
+		log.Fatalf("failed to start server: %v", err)
+	}
+	if tlsEnabled {
+		ln = tls.NewListener(ln, tlsconfig.ServerConfig())
+	}
+
+	// Redirect plain HTTP to HTTPS on its own listener when enabled
+	var redirectApp *fiber.App
+	if redirectPort := pkgConfig.GetEnv("HTTP_REDIRECT_PORT"); redirectPort != "" && !tlsEnabled {
+		log.Println("Warning: HTTP_REDIRECT_PORT is ignored because tls.enabled is false")
+	} else if redirectPort != "" {
+		redirectApp = setupRedirectApp(pkgConfig.GetEnv("PORT"))
+		go func() {
+			if err := redirectApp.Listen(":" + redirectPort); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("failed to start redirect server: %v", err)
+			}
+		}()
+	}
+
 	// Start server in a goroutine
 	go func() {
-		if err := app.Listen(":" + pkgConfig.GetEnv("PORT")); err != nil && err != http.ErrServerClosed {
+		if err := app.Listener(ln); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("failed to start server: %v", err)
 		}
 	}()
@@ -90,9 +282,21 @@ func main() {
 	// Wait for shutdown signal
 	<-quit
 	log.Println("Shutting down server...")
+	close(stopWatcher)
+	health.Stop()
 
-	// Gracefully shutdown the server
+	// Gracefully shutdown the servers
 	if err := app.Shutdown(); err != nil {
 		log.Printf("error shutting down server: %v", err)
 	}
+	if adminApp != nil {
+		if err := adminApp.Shutdown(); err != nil {
+			log.Printf("error shutting down admin server: %v", err)
+		}
+	}
+	if redirectApp != nil {
+		if err := redirectApp.Shutdown(); err != nil {
+			log.Printf("error shutting down redirect server: %v", err)
+		}
+	}
 }